@@ -2,27 +2,87 @@
 package cachefiles
 
 import (
-	"bytes"
+	"container/list"
 	"context"
+	"errors"
+	"net/http"
 	"net/url"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/puellanivis/breton/lib/files"
+	"github.com/puellanivis/breton/lib/files/httpfiles"
 	"github.com/puellanivis/breton/lib/files/wrapper"
 )
 
 type line struct {
+	key  string
 	info os.FileInfo
-	data []byte
+
+	// Content is either held in memory as data,
+	// or else on disk, addressed by the digest of its content.
+	data   []byte
+	digest string
+	size   int64
+
+	// err is set for a negatively cached line, recording that the content does not exist.
+	err error
+
+	header  http.Header
+	expires time.Time
+	retain  time.Time
+
+	timer *time.Timer
+	elem  *list.Element
+}
+
+func (l *line) fresh(now time.Time) bool {
+	return now.Before(l.expires)
+}
+
+// setExpiry sets when the line becomes stale, and how long the line should be retained.
+//
+// Lines that can be revalidated are retained past their freshness for an additional lifetime,
+// so that they may be refreshed through a conditional request, rather than fetched again.
+func (l *line) setExpiry(expires time.Time, lifetime time.Duration) {
+	l.expires = expires
+	l.retain = expires
+
+	if validators(l.header) != nil {
+		l.retain = expires.Add(lifetime)
+	}
 }
 
 // FileStore is a caching structure that holds copies of the content of files.
+//
+// The zero value holds content in memory without any size limit.
+// Use NewFileStore to make a FileStore that holds content on disk, or with a size limit.
 type FileStore struct {
 	mu sync.RWMutex
 
-	cache map[string]*line
+	cache    map[string]*line
+	inflight map[string]*call
+
+	dir         string
+	maxSize     int64
+	negativeTTL time.Duration
+
+	lru lru
+}
+
+// call is a fetch from the origin in progress, which other concurrent Opens of the same URL wait on.
+type call struct {
+	done chan struct{}
+
+	// f and err are only valid after done is closed.
+	f   *line
+	err error
+
+	// invalidated is set under the write lock,
+	// if the result of the fetch must not be cached when it completes.
+	invalidated bool
 }
 
 // New returns a new caching FileStore, which can be registered into lib/files
@@ -33,6 +93,30 @@ func New() *FileStore {
 	return &FileStore{}
 }
 
+// NewFileStore returns a new caching FileStore with the given Options applied.
+//
+// If a directory has been given with WithDirectory,
+// then it is created if necessary, and any content already cached in it is loaded.
+func NewFileStore(opts ...Option) (*FileStore, error) {
+	h := new(FileStore)
+
+	for _, opt := range opts {
+		// intentionally throwing away the reverting functions.
+		_ = opt(h)
+	}
+
+	if h.dir != "" {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		if err := h.load(); err != nil {
+			return nil, err
+		}
+	}
+
+	return h, nil
+}
+
 // Default is the default cache attached to the "cache" Scheme
 var Default = new(FileStore)
 
@@ -40,11 +124,33 @@ func init() {
 	files.RegisterScheme(Default, "cache")
 }
 
-func (h *FileStore) expire(filename string) {
+// defaultExpiration is how long content is cached if neither the origin, nor the context.Context specify.
+const defaultExpiration = 5 * time.Minute
+
+// expire removes the given line from the cache, unless it has already been replaced.
+func (h *FileStore) expire(f *line) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	delete(h.cache, filename)
+	if h.cache[f.key] == f {
+		h.remove(f)
+	}
+}
+
+// schedule sets up the removal of the given line from the cache, once it is no longer retained.
+//
+// Caller MUST hold the write lock.
+func (h *FileStore) schedule(f *line) {
+	d := time.Until(f.retain)
+
+	if f.timer != nil {
+		f.timer.Reset(d)
+		return
+	}
+
+	f.timer = time.AfterFunc(d, func() {
+		h.expire(f)
+	})
 }
 
 func trimScheme(uri *url.URL) string {
@@ -54,7 +160,67 @@ func trimScheme(uri *url.URL) string {
 	return u.String()
 }
 
-// Open implements the files.FileStore Open. It returns a buffered copy of the files.Reader returned from reading the uri escaped by the "cache:" scheme. Any access within the next ExpireTime set by the context.Context (5 minutes by default) will return a new copy of an bytes.Reader of the same buffer.
+type headerer interface {
+	Header() (http.Header, error)
+}
+
+func withHeaders(header http.Header) []files.Option {
+	var opts []files.Option
+
+	for key, values := range header {
+		opts = append(opts, httpfiles.WithHeader(key, values...))
+	}
+
+	return opts
+}
+
+// serve returns a new files.Reader of the content of the line,
+// or the error recorded for a negatively cached line.
+func (h *FileStore) serve(f *line) (files.Reader, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	return h.newReader(f)
+}
+
+// lookup returns the line cached under the given key, if it is fresh.
+func (h *FileStore) lookup(key string) (r files.Reader, ok bool, err error) {
+	h.mu.RLock()
+	f := h.cache[key]
+	h.mu.RUnlock()
+
+	if f == nil || !f.fresh(time.Now()) {
+		return nil, false, nil
+	}
+
+	r, err = h.serve(f)
+	if err != nil && f.err == nil {
+		// Our content has gone missing, so we will need to fetch it again.
+		return nil, false, nil
+	}
+
+	hits.Inc()
+	h.touch(f)
+
+	return r, true, err
+}
+
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// Open implements the files.FileStore Open. It returns a buffered copy of the files.Reader returned from reading the uri escaped by the "cache:" scheme.
+//
+// Any access while the content is still fresh will return a new copy of a reader of the same content.
+// Freshness is determined by any Cache-Control or Expires headers sent by an HTTP origin,
+// otherwise by the ExpireTime set by the context.Context (5 minutes by default).
+//
+// Stale content from an HTTP origin with an ETag or Last-Modified header is revalidated with a conditional request,
+// and if the origin responds with 304 Not Modified, the cached content is refreshed without downloading it again.
+//
+// Concurrent Opens of the same uncached URL share a single fetch from the origin,
+// while Opens of different URLs proceed independently.
 func (h *FileStore) Open(ctx context.Context, uri *url.URL) (files.Reader, error) {
 	filename := trimScheme(uri)
 
@@ -65,26 +231,217 @@ func (h *FileStore) Open(ctx context.Context, uri *url.URL) (files.Reader, error
 		return files.Open(ctx, filename)
 	}
 
-	h.mu.RLock()
-	f := h.cache[filename]
-	h.mu.RUnlock()
+	for {
+		if r, ok, err := h.lookup(filename); ok {
+			return r, err
+		}
+
+		h.mu.Lock()
+
+		if c := h.inflight[filename]; c != nil {
+			h.mu.Unlock()
+
+			select {
+			case <-c.done:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+
+			if c.err != nil {
+				if isContextError(c.err) {
+					// The fetch was canceled by the context of whoever started it, not ours, so try again.
+					continue
+				}
+
+				return nil, c.err
+			}
+
+			if c.f != nil {
+				if r, err := h.newReader(c.f); err == nil {
+					hits.Inc()
+					return r, nil
+				}
+			}
+
+			// The content could not be cached, so we have to fetch it ourselves.
+			continue
+		}
+
+		f := h.cache[filename]
+		if f != nil && f.fresh(time.Now()) {
+			if r, err := h.serve(f); err == nil || f.err != nil {
+				// Another goroutine already did our work.
+				h.mu.Unlock()
+
+				hits.Inc()
+				h.touch(f)
 
-	if f != nil {
-		return wrapper.NewReaderWithInfo(bytes.NewReader(f.data), f.info), nil
+				return r, err
+			}
+
+			// Our content has gone missing, so fall through to fetch it again.
+		}
+
+		c := &call{
+			done: make(chan struct{}),
+		}
+
+		if h.inflight == nil {
+			h.inflight = make(map[string]*call)
+		}
+		h.inflight[filename] = c
+
+		h.mu.Unlock()
+
+		return h.fill(ctx, uri, filename, f, c)
 	}
+}
+
+// fill fetches the content of the given URL, caches the result, and then completes the call.
+func (h *FileStore) fill(ctx context.Context, uri *url.URL, filename string, stale *line, c *call) (files.Reader, error) {
+	if stale != nil && stale.err != nil {
+		// Nothing to revalidate for a negatively cached line.
+		stale = nil
+	}
+
+	f, r, err := h.fetch(ctx, uri, filename, stale)
 
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	f = h.cache[filename]
-	if f != nil {
-		// Another goroutine already did our work.
-		return wrapper.NewReaderWithInfo(bytes.NewReader(f.data), f.info), nil
+	delete(h.inflight, filename)
+
+	c.f, c.err = f, err
+	defer close(c.done)
+
+	if c.invalidated {
+		if f != nil && f.digest != "" && !h.lru.referenced(f.digest) {
+			// Our reader already has the file open, so we can remove it out from under it.
+			_ = os.Remove(h.blobPath(f.digest))
+		}
+
+		c.f = nil
+		return r, err
+	}
+
+	switch {
+	case err != nil:
+		if h.negativeTTL <= 0 || !errors.Is(err, os.ErrNotExist) {
+			// Leave any stale line in place, it may be revalidated later.
+			return nil, err
+		}
+
+		f = &line{
+			key: filename,
+			err: err,
+		}
+		f.setExpiry(time.Now().Add(h.negativeTTL), 0)
+
+		c.f, c.err = nil, err
+
+	case f == nil:
+		// We must not cache this content.
+		if old := h.cache[filename]; old != nil {
+			h.remove(old)
+		}
+
+		return r, nil
 	}
 
-	raw, err := files.Open(ctx, filename)
+	// Schedule before inserting, so that if the line is immediately evicted, its timer is also stopped.
+	h.schedule(f)
+	h.insert(f)
+
+	if h.cache[filename] == f && f.err == nil {
+		h.save(f)
+	}
+
+	return r, err
+}
+
+// fetch fetches the content of the given URL, revalidating the stale line if possible.
+//
+// It returns the line to be cached, and a files.Reader of the content.
+// If the content must not be cached, then the line returned is nil.
+func (h *FileStore) fetch(ctx context.Context, uri *url.URL, filename string, stale *line) (*line, files.Reader, error) {
+	lifetime := defaultExpiration
+	if d, ok := GetExpire(ctx); ok {
+		lifetime = d
+	}
+
+	var staleReader files.Reader
+	var cond http.Header
+
+	if stale != nil {
+		if r, err := h.newReader(stale); err == nil {
+			staleReader = r
+			cond = validators(stale.header)
+		}
+
+		// Otherwise, our content has gone missing, so we cannot revalidate.
+	}
+
+	if staleReader != nil {
+		defer func() {
+			if staleReader != nil {
+				staleReader.Close()
+			}
+		}()
+	}
+
+	raw, err := files.Open(ctx, filename, withHeaders(cond)...)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	var header http.Header
+	if r, ok := raw.(headerer); ok {
+		var err error
+
+		header, err = r.Header()
+
+		if cond != nil && errors.Is(err, httpfiles.ErrNotModified) {
+			raw.Close()
+
+			// RFC 9111 §4.3.4: update the stored headers with the ones from the 304 response.
+			merged := stale.header.Clone()
+			for key, values := range header {
+				merged[key] = values
+			}
+
+			expires, _ := freshness(merged, time.Now(), lifetime)
+
+			// Lines are not modified once cached, so we make a fresh copy.
+			f := &line{
+				key:  filename,
+				info: stale.info,
+
+				data:   stale.data,
+				digest: stale.digest,
+				size:   stale.size,
+
+				header: merged,
+			}
+			f.setExpiry(expires, lifetime)
+
+			revalidated.Inc()
+
+			r := staleReader
+			staleReader = nil
+			return f, r, nil
+		}
+
+		// Any other error will be returned from reading below.
+	}
+
+	misses.Inc()
+
+	now := time.Now()
+
+	expires, store := freshness(header, now, lifetime)
+	if !store {
+		// We must not cache this content, so hand back the underlying reader.
+		return nil, raw, nil
 	}
 
 	info, err := raw.Stat()
@@ -95,39 +452,74 @@ func (h *FileStore) Open(ctx context.Context, uri *url.URL) (files.Reader, error
 		info = nil
 	}
 
-	data, err := files.ReadFrom(raw)
-	if err != nil {
-		return nil, err
+	f := &line{
+		key:    filename,
+		header: header,
 	}
+	f.setExpiry(expires, lifetime)
 
-	if info == nil {
-		info = wrapper.NewInfo(uri, len(data), time.Now())
+	if h.dir == "" {
+		data, err := files.ReadFrom(raw)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		f.data, f.size = data, int64(len(data))
+
+	} else {
+		digest, size, err := h.writeBlob(raw)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		f.digest, f.size = digest, size
 	}
 
-	f = &line{
-		data: data,
-		info: info,
+	if info == nil {
+		info = wrapper.NewInfo(uri, int(f.size), now)
 	}
+	f.info = info
 
-	if h.cache == nil {
-		h.cache = make(map[string]*line)
+	r, err := h.newReader(f)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	h.cache[filename] = f
+	return f, r, nil
+}
 
-	// default 5 minute expiration
-	expiration := 5 * time.Minute
-	if d, ok := GetExpire(ctx); ok {
-		expiration = d
+// Invalidate removes any content cached for the given URL,
+// and ensures that any fetch of that URL already in progress will not be cached.
+//
+// The URL is the URL of the original content, though a leading "cache:" is also accepted.
+func (h *FileStore) Invalidate(uri string) {
+	uri = strings.TrimPrefix(uri, "cache:")
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if c := h.inflight[uri]; c != nil {
+		c.invalidated = true
 	}
-	timer := time.NewTimer(expiration)
 
-	go func() {
-		<-timer.C
-		h.expire(filename)
-	}()
+	if f := h.cache[uri]; f != nil {
+		h.remove(f)
+	}
+}
+
+// Purge removes all content cached,
+// and ensures that any fetch already in progress will not be cached.
+func (h *FileStore) Purge() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, c := range h.inflight {
+		c.invalidated = true
+	}
 
-	return wrapper.NewReaderWithInfo(bytes.NewReader(data), info), nil
+	for _, f := range h.cache {
+		h.remove(f)
+	}
 }
 
 // Create implements the files.FileStore Create. At this time, it just returns the files.Create() from the wrapped url.
@@ -0,0 +1,331 @@
+package cachefiles
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/puellanivis/breton/lib/files"
+)
+
+func TestFreshness(t *testing.T) {
+	now := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	lifetime := 5 * time.Minute
+
+	tests := []struct {
+		name    string
+		header  http.Header
+		expires time.Time
+		store   bool
+	}{
+		{"no headers", http.Header{}, now.Add(lifetime), true},
+		{"max-age", http.Header{"Cache-Control": {"public, max-age=60"}}, now.Add(time.Minute), true},
+		{"max-age with age", http.Header{"Cache-Control": {"max-age=60"}, "Age": {"15"}}, now.Add(45 * time.Second), true},
+		{"no-store", http.Header{"Cache-Control": {"no-store"}}, now, false},
+		{"no-cache", http.Header{"Cache-Control": {"no-cache"}}, now, true},
+		{"invalid expires", http.Header{"Expires": {"0"}}, now, true},
+		{"expires with date", http.Header{
+			"Date":    {"Mon, 01 Jan 2001 00:00:00 GMT"},
+			"Expires": {"Mon, 01 Jan 2001 00:10:00 GMT"},
+		}, now.Add(10 * time.Minute), true},
+		{"max-age overrides expires", http.Header{
+			"Cache-Control": {"max-age=30"},
+			"Expires":       {"0"},
+		}, now.Add(30 * time.Second), true},
+	}
+
+	for _, tt := range tests {
+		expires, store := freshness(tt.header, now, lifetime)
+
+		if !expires.Equal(tt.expires) {
+			t.Errorf("%s: got expiration %v, expected %v", tt.name, expires, tt.expires)
+		}
+
+		if store != tt.store {
+			t.Errorf("%s: got store %v, expected %v", tt.name, store, tt.store)
+		}
+	}
+}
+
+func TestRevalidation(t *testing.T) {
+	var requests, bodies int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+
+		w.Header().Set("Cache-Control", "max-age=0")
+		w.Header().Set("ETag", `"v1"`)
+
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		atomic.AddInt32(&bodies, 1)
+		w.Write([]byte("ohai"))
+	}))
+	defer srv.Close()
+
+	uri, err := url.Parse("cache:" + srv.URL)
+	if err != nil {
+		t.Fatal("unexpected error parsing URL", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	h := new(FileStore)
+
+	for i := 0; i < 3; i++ {
+		f, err := h.Open(ctx, uri)
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+
+		b, err := files.ReadFrom(f)
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+
+		if string(b) != "ohai" {
+			t.Errorf("got %q, expected %q", b, "ohai")
+		}
+	}
+
+	if n := atomic.LoadInt32(&requests); n != 3 {
+		t.Errorf("got %d requests, expected 3", n)
+	}
+
+	if n := atomic.LoadInt32(&bodies); n != 1 {
+		t.Errorf("got %d bodies sent, expected 1", n)
+	}
+}
+
+func TestDiskStore(t *testing.T) {
+	var requests int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("0123456789"))
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "cachefiles")
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	defer os.RemoveAll(dir)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	read := func(h *FileStore, path string) {
+		t.Helper()
+
+		uri, err := url.Parse("cache:" + srv.URL + path)
+		if err != nil {
+			t.Fatal("unexpected error parsing URL", err)
+		}
+
+		f, err := h.Open(ctx, uri)
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+
+		b, err := files.ReadFrom(f)
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+
+		if string(b) != "0123456789" {
+			t.Errorf("got %q, expected %q", b, "0123456789")
+		}
+	}
+
+	h, err := NewFileStore(WithDirectory(dir), WithMaxSize(25))
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	read(h, "/a")
+	read(h, "/b")
+
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Errorf("got %d requests, expected 2", n)
+	}
+
+	if len(h.cache) != 2 {
+		t.Errorf("got %d lines cached, expected 2", len(h.cache))
+	}
+
+	h2, err := NewFileStore(WithDirectory(dir), WithMaxSize(25))
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	read(h2, "/a")
+	read(h2, "/b")
+
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Errorf("got %d requests after reloading, expected 2", n)
+	}
+}
+
+func TestEviction(t *testing.T) {
+	h, err := NewFileStore(WithMaxSize(25))
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, key := range []string{"a", "b", "c"} {
+		f := &line{
+			key:  key,
+			data: make([]byte, 10),
+			size: 10,
+		}
+		f.setExpiry(time.Now().Add(time.Minute), time.Minute)
+
+		h.schedule(f)
+		h.insert(f)
+	}
+
+	if h.cache["a"] != nil {
+		t.Error("expected least recently used line to be evicted")
+	}
+
+	if h.cache["b"] == nil || h.cache["c"] == nil {
+		t.Error("expected most recently used lines to be retained")
+	}
+
+	if h.lru.size != 20 {
+		t.Errorf("got total size %d, expected 20", h.lru.size)
+	}
+}
+
+func TestSingleFlight(t *testing.T) {
+	var requests int32
+
+	release := make(chan struct{})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+
+		if r.URL.Path == "/slow" {
+			<-release
+		}
+
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	h := new(FileStore)
+
+	open := func(path string) error {
+		uri, err := url.Parse("cache:" + srv.URL + path)
+		if err != nil {
+			return err
+		}
+
+		f, err := h.Open(ctx, uri)
+		if err != nil {
+			return err
+		}
+
+		_, err = files.ReadFrom(f)
+		return err
+	}
+
+	slow := make(chan error, 4)
+	for i := 0; i < cap(slow); i++ {
+		go func() {
+			slow <- open("/slow")
+		}()
+	}
+
+	// A slow fetch of one URL must not block fetches of other URLs.
+	if err := open("/fast"); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	release <- struct{}{}
+
+	for i := 0; i < cap(slow); i++ {
+		if err := <-slow; err != nil {
+			t.Fatal("unexpected error", err)
+		}
+	}
+
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Errorf("got %d requests, expected 2", n)
+	}
+
+	h.Invalidate(srv.URL + "/fast")
+
+	if err := open("/fast"); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if n := atomic.LoadInt32(&requests); n != 3 {
+		t.Errorf("got %d requests after invalidation, expected 3", n)
+	}
+}
+
+func TestNegativeCaching(t *testing.T) {
+	var requests int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+
+	uri, err := url.Parse("cache:" + srv.URL + "/missing")
+	if err != nil {
+		t.Fatal("unexpected error parsing URL", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	h, err := NewFileStore(WithNegativeCaching(time.Minute))
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := h.Open(ctx, uri); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("got error %v, expected os.ErrNotExist", err)
+		}
+	}
+
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("got %d requests, expected 1", n)
+	}
+
+	h.Purge()
+
+	if _, err := h.Open(ctx, uri); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("got error %v, expected os.ErrNotExist", err)
+	}
+
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Errorf("got %d requests after purge, expected 2", n)
+	}
+}
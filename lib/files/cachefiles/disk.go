@@ -0,0 +1,256 @@
+package cachefiles
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/puellanivis/breton/lib/files/wrapper"
+)
+
+// The layout of a cache directory is:
+//
+//	objects/ab/abcdef…	content, named by the hex SHA-256 digest of the content.
+//	index/0123…	an index record for each URL, named by the hex SHA-256 digest of the URL.
+//	tmp/	content in the process of being written.
+const (
+	objectsDir = "objects"
+	indexDir   = "index"
+	tmpDir     = "tmp"
+)
+
+// record is the persisted form of a line.
+type record struct {
+	Key    string `json:"key"`
+	Digest string `json:"digest"`
+	Size   int64  `json:"size"`
+
+	Name    string      `json:"name"`
+	ModTime time.Time   `json:"mod_time"`
+	Mode    os.FileMode `json:"mode"`
+
+	Header  http.Header `json:"header,omitempty"`
+	Expires time.Time   `json:"expires"`
+	Retain  time.Time   `json:"retain"`
+}
+
+func (h *FileStore) blobPath(digest string) string {
+	return filepath.Join(h.dir, objectsDir, digest[:2], digest)
+}
+
+func (h *FileStore) indexPath(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(h.dir, indexDir, hex.EncodeToString(sum[:])+".json")
+}
+
+// writeFile atomically writes to the given filename, by writing to a temporary file first, and then renaming it.
+func (h *FileStore) writeFile(filename string, fn func(w io.Writer) error) error {
+	tmp, err := ioutil.TempFile(filepath.Join(h.dir, tmpDir), "")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // After a successful rename, this fails harmlessly.
+
+	if err := fn(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filename)
+}
+
+// writeBlob stores the content of the io.Reader on disk, and returns its digest and size.
+// If the io.Reader is also an io.Closer, it is closed.
+func (h *FileStore) writeBlob(r io.Reader) (digest string, size int64, err error) {
+	if c, ok := r.(io.Closer); ok {
+		defer c.Close()
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Join(h.dir, tmpDir), "")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(tmp.Name()) // After a successful rename, this fails harmlessly.
+
+	hash := sha256.New()
+
+	size, err = io.Copy(io.MultiWriter(tmp, hash), r)
+	if err != nil {
+		tmp.Close()
+		return "", 0, err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return "", 0, err
+	}
+
+	digest = hex.EncodeToString(hash.Sum(nil))
+	filename := h.blobPath(digest)
+
+	if err := os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
+		return "", 0, err
+	}
+
+	if err := os.Rename(tmp.Name(), filename); err != nil {
+		return "", 0, err
+	}
+
+	return digest, size, nil
+}
+
+// save persists the index record of the line.
+// Persisting is best-effort, a failure only means the line will not be reloaded later.
+func (h *FileStore) save(f *line) {
+	if h.dir == "" {
+		return
+	}
+
+	rec := &record{
+		Key:    f.key,
+		Digest: f.digest,
+		Size:   f.size,
+
+		Name:    f.info.Name(),
+		ModTime: f.info.ModTime(),
+		Mode:    f.info.Mode(),
+
+		Header:  f.header,
+		Expires: f.expires,
+		Retain:  f.retain,
+	}
+
+	_ = h.writeFile(h.indexPath(f.key), func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(rec)
+	})
+}
+
+func (h *FileStore) removeIndex(key string) {
+	_ = os.Remove(h.indexPath(key))
+}
+
+// touchIndex updates the modification time of the index record,
+// which is used to restore the order of least recently used lines when reloading.
+func (h *FileStore) touchIndex(f *line) {
+	now := time.Now()
+	_ = os.Chtimes(h.indexPath(f.key), now, now)
+}
+
+// load reloads all of the lines persisted in the directory,
+// dropping any that are no longer retained, or whose content is missing,
+// and then removes any content that is no longer referenced.
+//
+// Caller MUST hold the write lock.
+func (h *FileStore) load() error {
+	for _, sub := range []string{objectsDir, indexDir, tmpDir} {
+		if err := os.MkdirAll(filepath.Join(h.dir, sub), 0700); err != nil {
+			return err
+		}
+	}
+
+	// Anything left in tmp is from an interrupted write.
+	if err := os.RemoveAll(filepath.Join(h.dir, tmpDir)); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Join(h.dir, tmpDir), 0700); err != nil {
+		return err
+	}
+
+	entries, err := ioutil.ReadDir(filepath.Join(h.dir, indexDir))
+	if err != nil {
+		return err
+	}
+
+	// Oldest used first, so that inserting leaves the most recently used at the front.
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ModTime().Before(entries[j].ModTime())
+	})
+
+	now := time.Now()
+
+	for _, entry := range entries {
+		filename := filepath.Join(h.dir, indexDir, entry.Name())
+
+		f := h.loadLine(filename, now)
+		if f == nil {
+			_ = os.Remove(filename)
+			continue
+		}
+
+		h.schedule(f)
+		h.insert(f)
+	}
+
+	return filepath.Walk(filepath.Join(h.dir, objectsDir), func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		if !h.lru.referenced(info.Name()) {
+			_ = os.Remove(path)
+		}
+
+		return nil
+	})
+}
+
+// loadLine reads an index record, and returns the line it describes,
+// or nil if the record is invalid, no longer retained, or its content is missing.
+func (h *FileStore) loadLine(filename string, now time.Time) *line {
+	if !strings.HasSuffix(filename, ".json") {
+		return nil
+	}
+
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil
+	}
+
+	var rec record
+	if err := json.Unmarshal(b, &rec); err != nil {
+		return nil
+	}
+
+	if len(rec.Digest) != sha256.Size*2 || h.indexPath(rec.Key) != filename {
+		return nil
+	}
+
+	if !now.Before(rec.Retain) {
+		return nil
+	}
+
+	fi, err := os.Stat(h.blobPath(rec.Digest))
+	if err != nil || fi.Size() != rec.Size {
+		return nil
+	}
+
+	info := wrapper.NewInfo(nil, int(rec.Size), rec.ModTime)
+	info.SetName(rec.Name)
+	_ = info.Chmod(rec.Mode)
+
+	return &line{
+		key:    rec.Key,
+		info:   info,
+		digest: rec.Digest,
+		size:   rec.Size,
+
+		header:  rec.Header,
+		expires: rec.Expires,
+		retain:  rec.Retain,
+	}
+}
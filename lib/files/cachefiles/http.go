@@ -0,0 +1,122 @@
+package cachefiles
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cacheControl holds the directives of a Cache-Control header that are relevant to us.
+type cacheControl struct {
+	noStore bool
+	noCache bool
+
+	maxAge    time.Duration
+	hasMaxAge bool
+}
+
+func parseCacheControl(header http.Header) cacheControl {
+	var cc cacheControl
+
+	for _, value := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			directive = strings.ToLower(strings.TrimSpace(directive))
+
+			name, arg := directive, ""
+			if i := strings.IndexByte(directive, '='); i >= 0 {
+				name, arg = directive[:i], strings.Trim(directive[i+1:], `"`)
+			}
+
+			switch name {
+			case "no-store":
+				cc.noStore = true
+
+			case "no-cache":
+				cc.noCache = true
+
+			case "max-age":
+				secs, err := strconv.ParseInt(arg, 10, 64)
+				if err != nil || secs < 0 {
+					// RFC 9111 §4.2.1: an invalid max-age means the response is stale.
+					secs = 0
+				}
+
+				cc.maxAge = time.Duration(secs) * time.Second
+				cc.hasMaxAge = true
+			}
+		}
+	}
+
+	return cc
+}
+
+// freshness returns when a response with the given headers received at now becomes stale,
+// and whether the response may be stored at all.
+//
+// If the headers specify no explicit freshness, then the given default lifetime is used.
+func freshness(header http.Header, now time.Time, lifetime time.Duration) (expires time.Time, store bool) {
+	if header == nil {
+		return now.Add(lifetime), true
+	}
+
+	cc := parseCacheControl(header)
+
+	if cc.noStore {
+		return now, false
+	}
+
+	if cc.noCache {
+		// We may store it, but it must be revalidated before every use.
+		return now, true
+	}
+
+	var age time.Duration
+	if secs, err := strconv.ParseInt(header.Get("Age"), 10, 64); err == nil && secs > 0 {
+		age = time.Duration(secs) * time.Second
+	}
+
+	if cc.hasMaxAge {
+		return now.Add(cc.maxAge - age), true
+	}
+
+	if _, ok := header["Expires"]; ok {
+		t, err := http.ParseTime(header.Get("Expires"))
+		if err != nil {
+			// RFC 9111 §5.3: an invalid Expires, especially "0", means already expired.
+			return now, true
+		}
+
+		// Use the server’s clock to measure lifetime, if we can, to avoid clock skew.
+		if date, err := http.ParseTime(header.Get("Date")); err == nil {
+			return now.Add(t.Sub(date) - age), true
+		}
+
+		return t, true
+	}
+
+	return now.Add(lifetime), true
+}
+
+// validators returns the headers necessary to make a conditional request to revalidate a response with the given headers.
+func validators(header http.Header) http.Header {
+	if header == nil {
+		return nil
+	}
+
+	cond := make(http.Header)
+
+	if etag := header.Get("ETag"); etag != "" {
+		cond.Set("If-None-Match", etag)
+	}
+
+	if lastmod := header.Get("Last-Modified"); lastmod != "" {
+		cond.Set("If-Modified-Since", lastmod)
+	}
+
+	if len(cond) < 1 {
+		return nil
+	}
+
+	return cond
+}
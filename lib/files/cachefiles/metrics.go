@@ -0,0 +1,14 @@
+package cachefiles
+
+import (
+	"github.com/puellanivis/breton/lib/metrics"
+)
+
+var (
+	hits        = metrics.Counter("cachefiles_hits_total", "number of cache lookups served from fresh cached content")
+	misses      = metrics.Counter("cachefiles_misses_total", "number of cache lookups that had to fetch from the origin")
+	revalidated = metrics.Counter("cachefiles_revalidations_total", "number of stale cache entries refreshed by a 304 Not Modified")
+	evictions   = metrics.Counter("cachefiles_evictions_total", "number of cache entries evicted to stay within the maximum size")
+
+	cachedBytes = metrics.Gauge("cachefiles_size_bytes", "total number of bytes of content held in caches")
+)
@@ -0,0 +1,56 @@
+package cachefiles
+
+import (
+	"time"
+)
+
+// Option defines a function that applies a specific setting to a FileStore.
+// It returns an Option that will revert the setting to its previous value.
+//
+// Options should only be applied before a FileStore is used.
+type Option func(*FileStore) Option
+
+// WithDirectory sets a local directory in which a FileStore will persist its cached content.
+//
+// Content is stored by the SHA-256 hash of its content, and an index records which URL maps to which content.
+// Content that is still in the directory when a new FileStore is made with NewFileStore is reused,
+// so long as it has not expired.
+//
+// If the directory is the empty string, then cached content is held only in memory.
+func WithDirectory(dir string) Option {
+	return func(h *FileStore) Option {
+		save := h.dir
+
+		h.dir = dir
+
+		return WithDirectory(save)
+	}
+}
+
+// WithMaxSize sets the maximum total number of bytes of content that a FileStore will hold.
+//
+// When adding content would exceed this size, then the least recently used content is evicted.
+// If the size is less than or equal to zero, then there is no limit.
+func WithMaxSize(size int64) Option {
+	return func(h *FileStore) Option {
+		save := h.maxSize
+
+		h.maxSize = size
+
+		return WithMaxSize(save)
+	}
+}
+
+// WithNegativeCaching sets how long a FileStore will remember that a URL does not exist.
+//
+// While remembered, any Open of that URL returns the same os.ErrNotExist error without trying to fetch it again.
+// If the duration is less than or equal to zero, then errors are not cached, which is the default.
+func WithNegativeCaching(ttl time.Duration) Option {
+	return func(h *FileStore) Option {
+		save := h.negativeTTL
+
+		h.negativeTTL = ttl
+
+		return WithNegativeCaching(save)
+	}
+}
@@ -0,0 +1,208 @@
+package cachefiles
+
+import (
+	"bytes"
+	"container/list"
+	"os"
+	"sync"
+
+	"github.com/puellanivis/breton/lib/files"
+	"github.com/puellanivis/breton/lib/files/wrapper"
+)
+
+// blob tracks content held on disk, which may be shared by more than one line.
+type blob struct {
+	size int64
+	refs int
+}
+
+// lru tracks the order in which lines have been used, and the total size of content held.
+type lru struct {
+	mu sync.Mutex
+
+	order list.List // of *line, most recently used at the front.
+	size  int64
+
+	blobs map[string]*blob
+}
+
+// add adds the line as the most recently used, and accounts for its size.
+func (l *lru) add(f *line) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f.elem = l.order.PushFront(f)
+
+	if f.digest == "" {
+		l.size += f.size
+		cachedBytes.Add(float64(f.size))
+		return
+	}
+
+	if l.blobs == nil {
+		l.blobs = make(map[string]*blob)
+	}
+
+	b := l.blobs[f.digest]
+	if b == nil {
+		b = &blob{
+			size: f.size,
+		}
+		l.blobs[f.digest] = b
+
+		l.size += b.size
+		cachedBytes.Add(float64(b.size))
+	}
+
+	b.refs++
+}
+
+// remove removes the line, and returns true if it held the last reference to its content on disk.
+func (l *lru) remove(f *line) (unreferenced bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if f.elem == nil {
+		return false
+	}
+
+	l.order.Remove(f.elem)
+	f.elem = nil
+
+	if f.digest == "" {
+		l.size -= f.size
+		cachedBytes.Sub(float64(f.size))
+		return false
+	}
+
+	b := l.blobs[f.digest]
+	if b == nil {
+		return false
+	}
+
+	b.refs--
+	if b.refs > 0 {
+		return false
+	}
+
+	delete(l.blobs, f.digest)
+
+	l.size -= b.size
+	cachedBytes.Sub(float64(b.size))
+
+	return true
+}
+
+// touch marks the line as the most recently used.
+func (l *lru) touch(f *line) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if f.elem != nil {
+		l.order.MoveToFront(f.elem)
+	}
+}
+
+// oldest returns the least recently used line, if the total size exceeds the given maximum size.
+func (l *lru) oldest(maxSize int64) *line {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if maxSize <= 0 || l.size <= maxSize {
+		return nil
+	}
+
+	e := l.order.Back()
+	if e == nil {
+		return nil
+	}
+
+	return e.Value.(*line)
+}
+
+// referenced returns true if the content on disk with the given digest is used by any line.
+func (l *lru) referenced(digest string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.blobs[digest] != nil
+}
+
+// newReader returns a new files.Reader of the content of the line.
+func (h *FileStore) newReader(f *line) (files.Reader, error) {
+	if f.digest == "" {
+		return wrapper.NewReaderWithInfo(bytes.NewReader(f.data), f.info), nil
+	}
+
+	file, err := os.Open(h.blobPath(f.digest))
+	if err != nil {
+		return nil, err
+	}
+
+	return wrapper.NewReaderWithInfo(file, f.info), nil
+}
+
+// touch marks the line as the most recently used.
+func (h *FileStore) touch(f *line) {
+	h.lru.touch(f)
+
+	if h.dir != "" {
+		h.touchIndex(f)
+	}
+}
+
+// insert adds the line to the cache, replacing any line already cached under the same key,
+// and then evicts the least recently used lines until the cache is within its maximum size.
+//
+// Caller MUST hold the write lock.
+func (h *FileStore) insert(f *line) {
+	if h.cache == nil {
+		h.cache = make(map[string]*line)
+	}
+
+	old := h.cache[f.key]
+
+	h.cache[f.key] = f
+	h.lru.add(f)
+
+	// Remove the old line only after adding the new one,
+	// otherwise identical content on disk would be released out from under the new line.
+	if old != nil && old != f {
+		h.remove(old)
+	}
+
+	for {
+		victim := h.lru.oldest(h.maxSize)
+		if victim == nil {
+			break
+		}
+
+		h.remove(victim)
+		evictions.Inc()
+	}
+}
+
+// remove removes the line from the cache, and releases any of its resources.
+//
+// Caller MUST hold the write lock.
+func (h *FileStore) remove(f *line) {
+	if h.cache[f.key] == f {
+		delete(h.cache, f.key)
+	}
+
+	if f.timer != nil {
+		f.timer.Stop()
+	}
+
+	unreferenced := h.lru.remove(f)
+
+	if h.dir == "" {
+		return
+	}
+
+	h.removeIndex(f.key)
+
+	if unreferenced {
+		_ = os.Remove(h.blobPath(f.digest))
+	}
+}
@@ -0,0 +1,257 @@
+package files
+
+import (
+	"crypto"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+
+	// Ensure the hashes for the checksums are available.
+	_ "crypto/md5"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+)
+
+// Checksummer is implemented by a files.Reader, when its backend provides a digest of the content.
+// For example, an S3 ETag or checksum, or an HTTP Digest or Content-MD5 header.
+type Checksummer interface {
+	// Checksum returns the digest of the whole content for the given hash,
+	// or an error wrapping ErrNotSupported if the backend does not provide a digest for that hash.
+	Checksum(h crypto.Hash) ([]byte, error)
+}
+
+// ChecksumMismatchError is returned when the checksum of the content read does not match the expected digest.
+type ChecksumMismatchError struct {
+	Hash     crypto.Hash
+	Expected []byte
+	Actual   []byte
+}
+
+func (e *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("%s checksum mismatch: expected %x, got %x", e.Hash, e.Expected, e.Actual)
+}
+
+// checksum is the hash to compute over content, and the digest it is expected to have.
+type checksum struct {
+	hash     crypto.Hash
+	expected []byte
+}
+
+func newChecksum(h crypto.Hash, expected []byte) (*checksum, hash.Hash, error) {
+	if !h.Available() {
+		return nil, nil, fmt.Errorf("checksum hash %v: %w", h, ErrNotSupported)
+	}
+
+	c := &checksum{
+		hash:     h,
+		expected: expected,
+	}
+
+	return c, h.New(), nil
+}
+
+// verify compares the sum to the expected digest.
+// If no digest was given, then the digest is taken from src, if it is a files.Checksummer.
+func (c *checksum) verify(src interface{}, sum []byte) error {
+	expected := c.expected
+
+	if expected == nil {
+		cs, ok := src.(Checksummer)
+		if !ok {
+			return fmt.Errorf("no expected %v checksum: %w", c.hash, ErrNotSupported)
+		}
+
+		var err error
+		if expected, err = cs.Checksum(c.hash); err != nil {
+			return err
+		}
+	}
+
+	if string(sum) != string(expected) {
+		return &ChecksumMismatchError{
+			Hash:     c.hash,
+			Expected: expected,
+			Actual:   sum,
+		}
+	}
+
+	return nil
+}
+
+// WithChecksum returns a CopyOption that computes the checksum of the content copied with the given hash,
+// and fails the copy with a *ChecksumMismatchError, if it does not match the expected digest.
+//
+// If expected is nil, then the digest is taken from the source, if it is a files.Checksummer.
+func WithChecksum(h crypto.Hash, expected []byte) CopyOption {
+	return withChecksum(&checksum{
+		hash:     h,
+		expected: expected,
+	})
+}
+
+func withChecksum(cs *checksum) CopyOption {
+	return func(c *copyConfig) CopyOption {
+		save := c.checksum
+
+		c.checksum = cs
+
+		return withChecksum(save)
+	}
+}
+
+// errNoChecksumSetter is returned by WithReadChecksum for a files.File that does not verify checksums itself,
+// so that files.Open knows to wrap the files.Reader in a checksumReader.
+var errNoChecksumSetter = fmt.Errorf("read checksum: %w", ErrNotSupported)
+
+// checksumReader computes the checksum of the content as it is read,
+// and once the whole content has been read, it returns a *ChecksumMismatchError instead of io.EOF, if the checksum does not match.
+type checksumReader struct {
+	Reader
+
+	c *checksum
+	h hash.Hash
+
+	// off is the offset being read, or negative if the checksum cannot be computed after a Seek.
+	off int64
+	err error
+}
+
+// SetChecksum sets the hash and expected digest to verify, and returns the previous values.
+// A zero hash disables the verification.
+func (r *checksumReader) SetChecksum(h crypto.Hash, expected []byte) (crypto.Hash, []byte, error) {
+	var c *checksum
+	var hasher hash.Hash
+
+	if h != 0 {
+		var err error
+		if c, hasher, err = newChecksum(h, expected); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	var prevHash crypto.Hash
+	var prevExpected []byte
+	if r.c != nil {
+		prevHash, prevExpected = r.c.hash, r.c.expected
+	}
+
+	r.c, r.h = c, hasher
+	r.off, r.err = 0, nil
+
+	return prevHash, prevExpected, nil
+}
+
+func (r *checksumReader) Read(b []byte) (n int, err error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+
+	n, err = r.Reader.Read(b)
+
+	if r.c == nil {
+		return n, err
+	}
+
+	if r.off >= 0 {
+		r.h.Write(b[:n])
+		r.off += int64(n)
+	}
+
+	if err == io.EOF && r.off >= 0 {
+		if verr := r.c.verify(r.Reader, r.h.Sum(nil)); verr != nil {
+			r.err = PathError("read", r.Name(), verr)
+			return n, r.err
+		}
+	}
+
+	return n, err
+}
+
+// Seek seeks the underlying reader.
+// Seeking back to the start restarts the checksum, while seeking anywhere else stops it from being verified.
+func (r *checksumReader) Seek(offset int64, whence int) (int64, error) {
+	off, err := r.Reader.Seek(offset, whence)
+	if err != nil || r.c == nil {
+		return off, err
+	}
+
+	switch {
+	case off == 0:
+		r.h.Reset()
+		r.off, r.err = 0, nil
+
+	case off != r.off:
+		r.off = -1
+	}
+
+	return off, nil
+}
+
+// Checksum forwards to the underlying files.Reader, if it is a files.Checksummer.
+func (r *checksumReader) Checksum(h crypto.Hash) ([]byte, error) {
+	cs, ok := r.Reader.(Checksummer)
+	if !ok {
+		return nil, PathError("checksum", r.Name(), ErrNotSupported)
+	}
+
+	return cs.Checksum(h)
+}
+
+// Header forwards to the underlying files.Reader, if it provides headers, as an http reader does.
+func (r *checksumReader) Header() (http.Header, error) {
+	type headerer interface {
+		Header() (http.Header, error)
+	}
+
+	hr, ok := r.Reader.(headerer)
+	if !ok {
+		return nil, PathError("header", r.Name(), ErrNotSupported)
+	}
+
+	return hr.Header()
+}
+
+// checksumReaderAt is a checksumReader over a files.Reader that is also an io.ReaderAt.
+// The ranges read with ReadAt are not part of the checksum, which is only computed by Read.
+type checksumReaderAt struct {
+	*checksumReader
+	io.ReaderAt
+}
+
+// newChecksumReader returns the checksumReader, and forwards io.ReaderAt only if the underlying files.Reader implements it.
+func newChecksumReader(r *checksumReader) Reader {
+	if ra, ok := r.Reader.(io.ReaderAt); ok {
+		return &checksumReaderAt{
+			checksumReader: r,
+			ReaderAt:       ra,
+		}
+	}
+
+	return r
+}
+
+// WithReadChecksum returns an Option for files.Open that computes the checksum of the content as it is read with the given hash.
+// Once the whole content is read, the reader returns a *ChecksumMismatchError (wrapped in an *os.PathError), instead of io.EOF,
+// if the checksum does not match the expected digest.
+//
+// If expected is nil, then the digest is taken from the reader, if it is a files.Checksummer.
+func WithReadChecksum(h crypto.Hash, expected []byte) Option {
+	type checksumSetter interface {
+		SetChecksum(crypto.Hash, []byte) (crypto.Hash, []byte, error)
+	}
+
+	return func(f File) (Option, error) {
+		r, ok := f.(checksumSetter)
+		if !ok {
+			return nil, errNoChecksumSetter
+		}
+
+		saveHash, saveExpected, err := r.SetChecksum(h, expected)
+		if err != nil {
+			return nil, err
+		}
+
+		return WithReadChecksum(saveHash, saveExpected), nil
+	}
+}
@@ -0,0 +1,131 @@
+package files
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/md5"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// checksummedReader is a bytes.Reader that reports a checksum, as a backend would.
+type checksummedReader struct {
+	*bytes.Reader
+
+	sum []byte
+}
+
+func (r *checksummedReader) Checksum(h crypto.Hash) ([]byte, error) {
+	if h != crypto.MD5 {
+		return nil, ErrNotSupported
+	}
+
+	return r.sum, nil
+}
+
+func TestCopyChecksum(t *testing.T) {
+	data := []byte("hello world")
+	sum := sha256.Sum256(data)
+
+	ctx := context.Background()
+
+	var buf bytes.Buffer
+
+	if _, err := Copy(ctx, &buf, bytes.NewReader(data), WithChecksum(crypto.SHA256, sum[:])); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	_, err := Copy(ctx, &buf, bytes.NewReader([]byte("hello wurld")), WithChecksum(crypto.SHA256, sum[:]))
+
+	var merr *ChecksumMismatchError
+	if !errors.As(err, &merr) {
+		t.Fatalf("got %v, expected a *ChecksumMismatchError", err)
+	}
+
+	if merr.Hash != crypto.SHA256 || !bytes.Equal(merr.Expected, sum[:]) {
+		t.Errorf("got %v, expected a mismatch with %x", merr, sum)
+	}
+
+	// The expected digest comes from the source.
+	md5sum := md5.Sum(data)
+	src := &checksummedReader{
+		Reader: bytes.NewReader(data),
+		sum:    md5sum[:],
+	}
+
+	if _, err := Copy(ctx, &buf, src, WithChecksum(crypto.MD5, nil)); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	src.Reset(data)
+	if _, err := Copy(ctx, &buf, src, WithChecksum(crypto.SHA256, nil)); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("got %v, expected ErrNotSupported", err)
+	}
+}
+
+func TestChecksumFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "files")
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	defer os.RemoveAll(dir)
+
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+	sum := sha256.Sum256(data)
+
+	name := dir + "/src"
+	if err := ioutil.WriteFile(name, data, 0644); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	ctx := context.Background()
+
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	defer f.Close()
+
+	var buf bytes.Buffer
+
+	if _, err := ParallelCopy(ctx, &buf, f, WithChecksum(crypto.SHA256, sum[:]), WithChunkSize(1000)); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	var merr *ChecksumMismatchError
+	if _, err := ParallelCopy(ctx, &buf, f, WithChecksum(crypto.MD5, sum[:]), WithChunkSize(1000)); !errors.As(err, &merr) {
+		t.Errorf("got %v, expected a *ChecksumMismatchError", err)
+	}
+
+	r, err := Open(ctx, name, WithReadChecksum(crypto.SHA256, sum[:]))
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	// The wrapper still allows the content to be read in parallel.
+	if _, ok := r.(io.ReaderAt); !ok {
+		t.Errorf("got %T, expected an io.ReaderAt", r)
+	}
+
+	if _, err := r.(Checksummer).Checksum(crypto.SHA256); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("got %v, expected %v", err, ErrNotSupported)
+	}
+
+	if _, err := ReadFrom(r); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	r, err = Open(ctx, name, WithReadChecksum(crypto.SHA1, sum[:]))
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if _, err := ReadFrom(r); !errors.As(err, &merr) {
+		t.Errorf("got %v, expected a *ChecksumMismatchError", err)
+	}
+}
@@ -3,8 +3,12 @@ package files
 import (
 	"context"
 	"errors"
+	"hash"
 	"io"
+	"os"
 	"time"
+
+	"github.com/puellanivis/breton/lib/io/throttle"
 )
 
 const defaultBufferSize = 64 * 1024
@@ -18,32 +22,67 @@ func (watchdogExpiredError) Error() string   { return "watchdog expired" }
 func (watchdogExpiredError) Timeout() bool   { return true }
 func (watchdogExpiredError) Temporary() bool { return true }
 
-// Copy is a context aware version of io.Copy.
-// Do not use to Discard a reader, as a canceled context would stop the read, and it would not be fully discarded.
-func Copy(ctx context.Context, dst io.Writer, src io.Reader, opts ...CopyOption) (written int64, err error) {
-	if dst == nil {
-		return 0, errors.New("nil io.Writer passed to files.Copy")
+// ETA returns the estimated time remaining for a files.Copy to complete, from the values passed to a WithProgress callback.
+// It returns a negative duration, if the total or rate are not known.
+func ETA(written, total int64, rate float64) time.Duration {
+	if total < 0 || rate <= 0 {
+		return -1
 	}
 
-	c := new(copyConfig)
+	if written >= total {
+		return 0
+	}
 
-	for _, opt := range opts {
-		// intentionally throwing away the reverting functions.
-		_ = opt(c)
+	return time.Duration(float64(total-written) / rate * float64(time.Second))
+}
+
+// sizeOf returns the size of the reader from its Stat(), or -1 if it is not known.
+func sizeOf(r io.Reader) int64 {
+	type stater interface {
+		Stat() (os.FileInfo, error)
 	}
 
-	if c.buffer == nil {
-		// we allocate a buffer to use as a temporary buffer, rather than alloc new every time.
-		c.buffer = make([]byte, defaultBufferSize)
+	s, ok := r.(stater)
+	if !ok {
+		return -1
 	}
-	buflen := int64(len(c.buffer))
 
-	var keepingMetrics bool
+	fi, err := s.Stat()
+	if err != nil || !fi.Mode().IsRegular() {
+		return -1
+	}
+
+	return fi.Size()
+}
+
+// copyMeter keeps the bandwidth metrics and progress of a copy.
+type copyMeter struct {
+	c *copyConfig
+
+	total   func(float64)
+	running func(float64)
+
+	window []bwSnippet
+
+	size    int64
+	written int64
+	accum   int64
+
+	start, last, next time.Time
+}
+
+type bwSnippet struct {
+	n int64
+	d time.Duration
+}
+
+func newCopyMeter(c *copyConfig, src io.Reader) *copyMeter {
+	m := &copyMeter{
+		c: c,
+	}
 
-	var total func(float64)
 	if c.bwLifetime != nil {
-		total = c.bwLifetime.Observe
-		keepingMetrics = true
+		m.total = c.bwLifetime.Observe
 	}
 
 	if c.bwScale <= 0 {
@@ -54,21 +93,142 @@ func Copy(ctx context.Context, dst io.Writer, src io.Reader, opts ...CopyOption)
 		c.bwInterval = 1 * time.Second
 	}
 
-	type bwSnippet struct {
-		n int64
-		d time.Duration
-	}
-	var bwWindow []bwSnippet
-
-	var running func(float64)
 	if c.bwRunning != nil {
 		if c.bwCount < 1 {
 			c.bwCount = 1
 		}
 
-		running = c.bwRunning.Observe
-		keepingMetrics = true
-		bwWindow = make([]bwSnippet, c.bwCount)
+		m.running = c.bwRunning.Observe
+		m.window = make([]bwSnippet, c.bwCount)
+	}
+
+	if c.progress != nil {
+		m.size = sizeOf(src)
+	}
+
+	m.start = time.Now()
+	m.last = m.start
+	m.next = m.last.Add(c.bwInterval)
+
+	return m
+}
+
+// add accounts n more bytes as copied, and reports the metrics and progress, if an interval has passed.
+func (m *copyMeter) add(n int64) {
+	m.written += n
+	m.accum += n
+
+	if m.total == nil && m.running == nil && m.c.progress == nil {
+		return
+	}
+
+	now := time.Now()
+	if !now.After(m.next) {
+		return
+	}
+
+	if m.total != nil {
+		dur := now.Sub(m.start)
+		m.total(float64(m.written) * m.c.bwScale / dur.Seconds())
+	}
+
+	if m.c.progress != nil {
+		dur := now.Sub(m.start)
+		m.c.progress(m.written, m.size, float64(m.written)/dur.Seconds())
+	}
+
+	if m.running != nil {
+		dur := now.Sub(m.last)
+
+		copy(m.window, m.window[1:])
+		m.window[len(m.window)-1].n = m.accum
+		m.window[len(m.window)-1].d = dur
+
+		var n int64
+		var d time.Duration
+		for i := range m.window {
+			n += m.window[i].n
+			d += m.window[i].d
+		}
+
+		m.running(float64(n) * m.c.bwScale / d.Seconds())
+	}
+
+	m.accum = 0
+	m.last = now
+	m.next = m.last.Add(m.c.bwInterval)
+}
+
+// done reports the final progress.
+func (m *copyMeter) done() {
+	if m.c.progress != nil {
+		dur := time.Since(m.start)
+		m.c.progress(m.written, m.size, float64(m.written)/dur.Seconds())
+	}
+}
+
+// newLimiter returns a throttle.Limiter for the maximum bandwidth, or nil if there is none.
+func (c *copyConfig) newLimiter() *throttle.Limiter {
+	if c.maxBandwidth <= 0 {
+		return nil
+	}
+
+	return throttle.New(int(c.maxBandwidth * 8))
+}
+
+// resetWatchdog restarts the watchdog timer, if there is a watchdog timeout.
+func (c *copyConfig) resetWatchdog(t *time.Timer) {
+	if c.runningTimeout > 0 {
+		if !t.Stop() {
+			<-t.C
+		}
+		t.Reset(c.runningTimeout)
+	}
+}
+
+// newWatchdog returns a timer for the watchdog timeout, which is stopped, and will never fire if there is none.
+func (c *copyConfig) newWatchdog() *time.Timer {
+	t := time.NewTimer(c.runningTimeout)
+	if c.runningTimeout <= 0 {
+		if !t.Stop() {
+			<-t.C
+		}
+	}
+
+	return t
+}
+
+// Copy is a context aware version of io.Copy.
+// Do not use to Discard a reader, as a canceled context would stop the read, and it would not be fully discarded.
+func Copy(ctx context.Context, dst io.Writer, src io.Reader, opts ...CopyOption) (written int64, err error) {
+	if dst == nil {
+		return 0, errors.New("nil io.Writer passed to files.Copy")
+	}
+
+	c := new(copyConfig)
+
+	for _, opt := range opts {
+		// intentionally throwing away the reverting functions.
+		_ = opt(c)
+	}
+
+	if c.buffer == nil {
+		// we allocate a buffer to use as a temporary buffer, rather than alloc new every time.
+		c.buffer = make([]byte, defaultBufferSize)
+	}
+	buflen := int64(len(c.buffer))
+
+	limiter := c.newLimiter()
+	if limiter != nil {
+		// Copy no more than a tenth of a second’s worth at a time, so the bandwidth is smooth.
+		if chunk := c.maxBandwidth / 10; chunk < buflen {
+			buflen = chunk
+			if buflen < 1 {
+				buflen = 1
+			}
+
+			c.buffer = c.buffer[:buflen]
+		}
 	}
 
 	// Prevent an accidental write outside of returning from this function.
@@ -80,34 +240,38 @@ func Copy(ctx context.Context, dst io.Writer, src io.Reader, opts ...CopyOption)
 		w:   dst,
 	}
 
+	var hasher hash.Hash
+	if c.checksum != nil {
+		var err error
+		if c.checksum, hasher, err = newChecksum(c.checksum.hash, c.checksum.expected); err != nil {
+			return 0, err
+		}
+	}
+
 	r := &fuzzyLimitedReader{
 		R: src,
 		N: buflen,
 	}
-
-	t := time.NewTimer(c.runningTimeout)
-	if c.runningTimeout <= 0 {
-		if !t.Stop() {
-			<-t.C
-		}
+	if hasher != nil {
+		r.R = io.TeeReader(src, hasher)
 	}
 
-	start := time.Now()
+	t := c.newWatchdog()
 
-	var bwAccum int64
-	last := start
-	next := last.Add(c.bwInterval)
+	m := newCopyMeter(c, src)
 
 	for {
 		r.N = buflen // reset fuzzyLimitedReader
 
-		if c.runningTimeout > 0 {
-			if !t.Stop() {
-				<-t.C
+		if limiter != nil {
+			// Wait out the limit before starting the watchdog, as it is not a lack of progress.
+			if err := limiter.Wait(ctx, 0); err != nil {
+				return m.written, err
 			}
-			t.Reset(c.runningTimeout)
 		}
 
+		c.resetWatchdog(t)
+
 		var n int64
 		done := make(chan struct{})
 		go func() {
@@ -124,53 +288,35 @@ func Copy(ctx context.Context, dst io.Writer, src io.Reader, opts ...CopyOption)
 		case <-done:
 
 		case <-t.C:
-			return written, ErrWatchdogExpired
+			return m.written, ErrWatchdogExpired
 
 		case <-ctx.Done():
-			return written, ctx.Err()
+			return m.written, ctx.Err()
 		}
 
 		// n and err are valid here because <-done HAPPENS AFTER close(done)
-		written += n
-		bwAccum += n
+		if limiter != nil {
+			// The wait is already done, this only spends the tokens.
+			_ = limiter.Wait(ctx, int(n))
+		}
+
 		if err != nil {
+			m.written += n
 			break
 		}
 
-		if keepingMetrics {
-			if now := time.Now(); now.After(next) {
-				if total != nil {
-					dur := now.Sub(start)
-					total(float64(written) * c.bwScale / dur.Seconds())
-				}
-
-				if running != nil {
-					dur := now.Sub(last)
-
-					copy(bwWindow, bwWindow[1:])
-					bwWindow[len(bwWindow)-1].n = bwAccum
-					bwWindow[len(bwWindow)-1].d = dur
-
-					var n int64
-					var d time.Duration
-					for i := range bwWindow {
-						n += bwWindow[i].n
-						d += bwWindow[i].d
-					}
-
-					running(float64(n) * c.bwScale / d.Seconds())
-				}
-
-				bwAccum = 0
-				last = now
-				next = last.Add(time.Second)
-			}
-		}
+		m.add(n)
 	}
 
+	m.done()
+
 	if err == io.EOF {
-		return written, nil
+		if hasher != nil {
+			return m.written, c.checksum.verify(src, hasher.Sum(nil))
+		}
+
+		return m.written, nil
 	}
 
-	return written, err
+	return m.written, err
 }
@@ -0,0 +1,99 @@
+package files
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCopyProgress(t *testing.T) {
+	f, err := ioutil.TempFile("", "files")
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	data := make([]byte, 3000)
+	if _, err := f.Write(data); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if _, err := f.Seek(0, 0); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	var calls int
+	var lastWritten, lastTotal int64
+
+	progress := func(written, total int64, rate float64) {
+		calls++
+		lastWritten, lastTotal = written, total
+
+		if rate <= 0 {
+			t.Errorf("got rate %v, expected a positive rate", rate)
+		}
+	}
+
+	var buf bytes.Buffer
+
+	start := time.Now()
+
+	n, err := Copy(context.Background(), &buf, f,
+		WithMaxBandwidth(10000),
+		WithProgress(progress),
+		WithIntervalBandwidthMetrics(nil, 1, 100*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if n != int64(len(data)) || buf.Len() != len(data) {
+		t.Errorf("got %d bytes, expected %d", n, len(data))
+	}
+
+	// The first 1000 bytes go immediately, so it takes at least 200ms for the rest.
+	if d := time.Since(start); d < 150*time.Millisecond {
+		t.Errorf("copy took %v, expected it to be limited to about 300ms", d)
+	}
+
+	if calls < 2 {
+		t.Errorf("got %d calls to progress, expected at least 2", calls)
+	}
+
+	if lastWritten != int64(len(data)) || lastTotal != int64(len(data)) {
+		t.Errorf("got final progress of %d of %d, expected %d of %d", lastWritten, lastTotal, len(data), len(data))
+	}
+}
+
+func TestCopyUnknownTotal(t *testing.T) {
+	var total int64
+
+	_, err := Copy(context.Background(), ioutil.Discard, bytes.NewReader(make([]byte, 100)), WithProgress(func(_, t int64, _ float64) {
+		total = t
+	}))
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if total != -1 {
+		t.Errorf("got total %d, expected -1", total)
+	}
+}
+
+func TestETA(t *testing.T) {
+	if eta := ETA(500, 1500, 100); eta != 10*time.Second {
+		t.Errorf("got %v, expected %v", eta, 10*time.Second)
+	}
+
+	if eta := ETA(500, -1, 100); eta >= 0 {
+		t.Errorf("got %v, expected a negative ETA for an unknown total", eta)
+	}
+
+	if eta := ETA(1500, 1500, 100); eta != 0 {
+		t.Errorf("got %v, expected 0", eta)
+	}
+}
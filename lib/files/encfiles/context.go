@@ -0,0 +1,36 @@
+package encfiles
+
+import (
+	"context"
+)
+
+type (
+	keyProvider struct{}
+	keyKeyID    struct{}
+)
+
+// WithKeyProvider returns a Context that includes the KeyProvider to use to get keys to encrypt and decrypt content.
+func WithKeyProvider(ctx context.Context, p KeyProvider) context.Context {
+	return context.WithValue(ctx, keyProvider{}, p)
+}
+
+// GetKeyProvider returns the KeyProvider specified for the given Context.
+func GetKeyProvider(ctx context.Context) (KeyProvider, bool) {
+	p, ok := ctx.Value(keyProvider{}).(KeyProvider)
+
+	return p, ok
+}
+
+// WithKeyID returns a Context that includes the ID of the key to encrypt content with.
+//
+// Decrypting content does not need a key ID, as it is recorded in the encrypted content.
+func WithKeyID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, keyKeyID{}, id)
+}
+
+// GetKeyID returns the ID of the key to encrypt content with specified for the given Context.
+func GetKeyID(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(keyKeyID{}).(string)
+
+	return id, ok
+}
@@ -0,0 +1,79 @@
+// Package encfiles implements the "enc:" URL scheme, which transparently encrypts and decrypts the content of any other files URL.
+//
+// The URL of the content is given as the opaque part of the URL, for example "enc:s3://bucket/export.csv", or "enc:sftp://host/export.csv".
+// Content is encrypted with AES-GCM in authenticated chunks, so that it can be streamed,
+// and so that any truncation or tampering of the content is detected while it is read.
+//
+// The keys are given by the KeyProvider attached to the context.Context with WithKeyProvider,
+// such as a KeyURLs, which reads each key from a files URL.
+// Content is encrypted with the key given by WithKeyID, and the key ID is recorded in the encrypted content,
+// so that the right key is used to decrypt it.
+//
+//	ctx = encfiles.WithKeyProvider(ctx, encfiles.KeyURLs{
+//		"2024-01": "home:.keys/export-2024-01.key",
+//	})
+//	ctx = encfiles.WithKeyID(ctx, "2024-01")
+//
+//	w, err := files.Create(ctx, "enc:s3://bucket/export.csv")
+package encfiles
+
+import (
+	"context"
+	"net/url"
+	"os"
+
+	"github.com/puellanivis/breton/lib/files"
+)
+
+type handler struct{}
+
+func init() {
+	files.RegisterScheme(&handler{}, "enc")
+}
+
+// trimScheme returns the URL of the content wrapped by the given "enc:" URL.
+func trimScheme(uri *url.URL) string {
+	u := *uri
+	u.Scheme = ""
+
+	return u.String()
+}
+
+func (h *handler) Open(ctx context.Context, uri *url.URL) (files.Reader, error) {
+	f, err := files.Open(ctx, trimScheme(uri))
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := newReader(ctx, uri, f)
+	if err != nil {
+		f.Close()
+		return nil, files.PathError("open", uri.String(), err)
+	}
+
+	return r, nil
+}
+
+func (h *handler) Create(ctx context.Context, uri *url.URL) (files.Writer, error) {
+	f, err := files.Create(ctx, trimScheme(uri))
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := newWriter(ctx, uri, f, defaultChunkSize)
+	if err != nil {
+		f.Close()
+		return nil, files.PathError("create", uri.String(), err)
+	}
+
+	return w, nil
+}
+
+func (h *handler) List(ctx context.Context, uri *url.URL) ([]os.FileInfo, error) {
+	return files.ReadDir(ctx, trimScheme(uri))
+}
+
+// MkdirAll makes the directory at the wrapped URL, as the directories themselves are not encrypted.
+func (h *handler) MkdirAll(ctx context.Context, uri *url.URL, perm os.FileMode) error {
+	return files.MkdirAll(ctx, trimScheme(uri), perm)
+}
@@ -0,0 +1,294 @@
+package encfiles
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/puellanivis/breton/lib/files"
+)
+
+func testContext(t *testing.T, dir string) context.Context {
+	key := make([]byte, 32)
+	rand.Read(key)
+
+	keyFile := dir + "/test.key"
+	if err := ioutil.WriteFile(keyFile, []byte(hex.EncodeToString(key)+"\n"), 0600); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	other := make([]byte, 16)
+	rand.Read(other)
+
+	otherFile := dir + "/other.key"
+	if err := ioutil.WriteFile(otherFile, other, 0600); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	ctx := WithKeyProvider(context.Background(), KeyURLs{
+		"test":  keyFile,
+		"other": otherFile,
+	})
+
+	return WithKeyID(ctx, "test")
+}
+
+func TestRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "encfiles")
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	defer os.RemoveAll(dir)
+
+	ctx := testContext(t, dir)
+
+	for _, size := range []int{0, 1, defaultChunkSize - 1, defaultChunkSize, 3*defaultChunkSize + 1234} {
+		data := make([]byte, size)
+		rand.Read(data)
+
+		name := dir + "/data"
+
+		if err := files.Write(ctx, "enc:"+name, data); err != nil {
+			t.Fatal("unexpected error", err)
+		}
+
+		raw, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+
+		if size >= 16 && bytes.Contains(raw, data) {
+			t.Errorf("size %d: plaintext found in the encrypted content", size)
+		}
+
+		r, err := files.Open(ctx, "enc:"+name)
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+
+		fi, err := r.Stat()
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+
+		if fi.Size() != int64(size) {
+			t.Errorf("got a size of %d, expected %d", fi.Size(), size)
+		}
+
+		b, err := files.ReadFrom(r)
+		if err != nil {
+			t.Fatalf("size %d: unexpected error: %v", size, err)
+		}
+
+		if !bytes.Equal(b, data) {
+			t.Errorf("size %d: got %d bytes, expected the %d bytes written", size, len(b), len(data))
+		}
+	}
+
+	// The key ID is recorded in the content, so the other key is used to decrypt it.
+	if err := files.Write(WithKeyID(ctx, "other"), "enc:"+dir+"/other", []byte("hello world")); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	b, err := files.Read(ctx, "enc:"+dir+"/other")
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if string(b) != "hello world" {
+		t.Errorf("got %q, expected %q", b, "hello world")
+	}
+
+	if _, err := files.Create(WithKeyID(ctx, "missing"), "enc:"+dir+"/missing"); err == nil {
+		t.Error("expected an error for an unknown key id, got none")
+	}
+}
+
+func TestTamper(t *testing.T) {
+	dir, err := ioutil.TempDir("", "encfiles")
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	defer os.RemoveAll(dir)
+
+	ctx := testContext(t, dir)
+
+	name := dir + "/data"
+	data := make([]byte, 2*defaultChunkSize+100)
+	rand.Read(data)
+
+	if err := files.Write(ctx, "enc:"+name, data); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	raw, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	_, hdr, err := readHeader(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	frameSize := defaultChunkSize + 16
+
+	flipped := append([]byte(nil), raw...)
+	flipped[len(hdr)+frameSize+10] ^= 1
+
+	swapped := append([]byte(nil), raw[:len(hdr)]...)
+	swapped = append(swapped, raw[len(hdr)+frameSize:len(hdr)+2*frameSize]...)
+	swapped = append(swapped, raw[len(hdr):len(hdr)+frameSize]...)
+	swapped = append(swapped, raw[len(hdr)+2*frameSize:]...)
+
+	tests := []struct {
+		name   string
+		b      []byte
+		expect error
+	}{
+		{"truncated at a frame", raw[:len(hdr)+2*frameSize], ErrTruncated},
+		{"truncated in a frame", raw[:len(raw)-1], ErrAuthentication},
+		{"truncated header", raw[:len(hdr)-1], ErrTruncated},
+		{"flipped bit", flipped, ErrAuthentication},
+		{"swapped frames", swapped, ErrAuthentication},
+	}
+
+	for _, tt := range tests {
+		if err := ioutil.WriteFile(name, tt.b, 0644); err != nil {
+			t.Fatal("unexpected error", err)
+		}
+
+		_, err := files.Read(ctx, "enc:"+name)
+		if !errors.Is(err, tt.expect) {
+			t.Errorf("%s: got %v, expected %v", tt.name, err, tt.expect)
+		}
+	}
+}
+
+func TestPerFileKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "encfiles")
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	defer os.RemoveAll(dir)
+
+	ctx := testContext(t, dir)
+
+	var raws [][]byte
+	var salts [][]byte
+
+	for _, name := range []string{dir + "/a", dir + "/b"} {
+		if err := files.Write(ctx, "enc:"+name, []byte("hello world")); err != nil {
+			t.Fatal("unexpected error", err)
+		}
+
+		raw, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+
+		h, hdr, err := readHeader(bytes.NewReader(raw))
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+
+		raws = append(raws, raw[len(hdr):])
+		salts = append(salts, h.salt[:])
+	}
+
+	if bytes.Equal(salts[0], salts[1]) {
+		t.Error("expected each file to have its own salt")
+	}
+
+	if bytes.Equal(raws[0], raws[1]) {
+		t.Error("expected the same content to encrypt differently in each file")
+	}
+
+	key := make([]byte, 32)
+
+	a, err := deriveKey(key, &header{salt: [saltSize]byte{1}})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	b, err := deriveKey(key, &header{salt: [saltSize]byte{2}})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if len(a) != len(key) || bytes.Equal(a, b) || bytes.Equal(a, key) {
+		t.Errorf("got derived keys %x and %x, expected distinct keys of %d bytes", a, b, len(key))
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	raw := make([]byte, 16)
+	for i := range raw {
+		raw[i] = byte(0x80 + i)
+	}
+
+	hexKey := []byte("00112233445566778899aabbccddeeff")
+	hexRaw, _ := hex.DecodeString(string(hexKey))
+
+	tests := []struct {
+		name   string
+		b      []byte
+		expect []byte
+	}{
+		{"raw", raw, raw},
+		{"hex", hexKey, hexRaw},
+		{"hex with newline", append(append([]byte(nil), hexKey...), '\n'), hexRaw},
+		{"base64", []byte(base64.StdEncoding.EncodeToString(raw)), raw},
+		// Both would be valid raw keys of 16 bytes, if raw bytes were tried first.
+		{"16 hex digits", []byte("0123456789abcdef"), nil},
+		{"printable raw", []byte("password12345678"), nil},
+	}
+
+	for _, tt := range tests {
+		key, err := parseKey(tt.b)
+
+		if tt.expect == nil {
+			if err == nil {
+				t.Errorf("%s: got key %x, expected an error", tt.name, key)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+
+		if !bytes.Equal(key, tt.expect) {
+			t.Errorf("%s: got key %x, expected %x", tt.name, key, tt.expect)
+		}
+	}
+}
+
+func TestChunkSizeLimit(t *testing.T) {
+	h, err := newHeader("test", defaultChunkSize)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	hdr := h.marshal()
+
+	// The chunk size follows the magic, version, key id length, and key id.
+	off := len(magic) + 2 + len(h.keyID)
+	binary.BigEndian.PutUint32(hdr[off:], 0xFFFFFFFF)
+
+	if _, _, err := readHeader(bytes.NewReader(hdr)); err == nil {
+		t.Error("expected an error for a chunk size of 0xFFFFFFFF, got none")
+	}
+
+	if _, err := newHeader("test", maxChunkSize+1); err == nil {
+		t.Error("expected an error for a chunk size over the maximum, got none")
+	}
+}
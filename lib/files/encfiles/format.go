@@ -0,0 +1,244 @@
+package encfiles
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// The encrypted content starts with a header:
+//
+//	magic       [4]byte  "BENC"
+//	version     uint8    2
+//	keyIDLen    uint8
+//	keyID       [keyIDLen]byte
+//	chunkSize   uint32   big-endian
+//	noncePrefix [7]byte
+//	salt        [32]byte
+//
+// The key is never used directly, instead each file is encrypted with its own key,
+// derived from the key and the random salt with HKDF-SHA256, and of the same length as the key.
+// So, the 56-bit noncePrefix does not have to be unique across every file encrypted with the same key.
+//
+// It is followed by the plaintext encrypted with AES-GCM in chunks of chunkSize bytes,
+// each frame being the ciphertext of the chunk followed by its 16 byte tag.
+//
+// The nonce of each chunk is the noncePrefix, followed by the big-endian uint32 index of the chunk,
+// and then a byte that is 1 for the final chunk, and 0 otherwise.
+// The whole header is the additional authenticated data of every chunk.
+//
+// The final chunk is always shorter than chunkSize, and may be empty.
+// So, every frame before the final frame is full, and a short frame is the final frame.
+// This way, any truncation is detected, even if it is at the boundary of a frame.
+
+const (
+	magic   = "BENC"
+	version = 2
+
+	noncePrefixSize = 7
+	saltSize        = 32
+
+	// hkdfInfo binds the derived keys to their use in this format.
+	hkdfInfo = "breton encfiles v2"
+
+	defaultChunkSize = 64 * 1024
+
+	// maxChunkSize bounds the chunk size of a header, as the chunk size is read before anything is authenticated,
+	// and a buffer of that size is allocated to read each frame.
+	maxChunkSize = 16 * 1024 * 1024
+)
+
+var (
+	// ErrTruncated is returned when encrypted content ends before its final chunk.
+	ErrTruncated = errors.New("encrypted content is truncated")
+
+	// ErrAuthentication is returned when encrypted content fails authentication, because it has been tampered with, or the key is wrong.
+	ErrAuthentication = errors.New("encrypted content failed authentication")
+)
+
+type header struct {
+	keyID       string
+	chunkSize   int
+	noncePrefix [noncePrefixSize]byte
+	salt        [saltSize]byte
+}
+
+func newHeader(keyID string, chunkSize int) (*header, error) {
+	if len(keyID) > math.MaxUint8 {
+		return nil, fmt.Errorf("key id is longer than %d bytes", math.MaxUint8)
+	}
+
+	if chunkSize < 1 || chunkSize > maxChunkSize {
+		return nil, fmt.Errorf("chunk size must be between 1 and %d bytes", maxChunkSize)
+	}
+
+	h := &header{
+		keyID:     keyID,
+		chunkSize: chunkSize,
+	}
+
+	if _, err := rand.Read(h.noncePrefix[:]); err != nil {
+		return nil, err
+	}
+
+	if _, err := rand.Read(h.salt[:]); err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+func (h *header) marshal() []byte {
+	b := make([]byte, 0, len(magic)+2+len(h.keyID)+4+noncePrefixSize+saltSize)
+
+	b = append(b, magic...)
+	b = append(b, version, byte(len(h.keyID)))
+	b = append(b, h.keyID...)
+	b = binary.BigEndian.AppendUint32(b, uint32(h.chunkSize))
+	b = append(b, h.noncePrefix[:]...)
+	b = append(b, h.salt[:]...)
+
+	return b
+}
+
+// readHeader reads the header from r, and returns it along with its raw bytes.
+func readHeader(r io.Reader) (*header, []byte, error) {
+	b := make([]byte, len(magic)+2)
+
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, nil, fmt.Errorf("reading header: %w", noEOF(err))
+	}
+
+	if string(b[:len(magic)]) != magic {
+		return nil, nil, errors.New("not encrypted content")
+	}
+
+	if b[len(magic)] != version {
+		return nil, nil, fmt.Errorf("unsupported version %d", b[len(magic)])
+	}
+
+	rest := make([]byte, int(b[len(magic)+1])+4+noncePrefixSize+saltSize)
+	if _, err := io.ReadFull(r, rest); err != nil {
+		return nil, nil, fmt.Errorf("reading header: %w", noEOF(err))
+	}
+
+	b = append(b, rest...)
+
+	idLen := len(rest) - 4 - noncePrefixSize - saltSize
+
+	h := &header{
+		keyID:     string(rest[:idLen]),
+		chunkSize: int(binary.BigEndian.Uint32(rest[idLen:])),
+	}
+	copy(h.noncePrefix[:], rest[idLen+4:])
+	copy(h.salt[:], rest[idLen+4+noncePrefixSize:])
+
+	if h.chunkSize < 1 || h.chunkSize > maxChunkSize {
+		return nil, nil, errors.New("invalid chunk size")
+	}
+
+	return h, b, nil
+}
+
+// noEOF converts an io.EOF into ErrTruncated, as the content should not end here.
+func noEOF(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return ErrTruncated
+	}
+
+	return err
+}
+
+// stream seals or opens the chunks of encrypted content in order.
+type stream struct {
+	aead cipher.AEAD
+	ad   []byte
+
+	nonce []byte
+	index uint64
+}
+
+// deriveKey returns the key of the file with the given header, derived from the key with HKDF-SHA256 and the salt of the header.
+func deriveKey(key []byte, h *header) ([]byte, error) {
+	fileKey := make([]byte, len(key))
+
+	if _, err := io.ReadFull(hkdf.New(sha256.New, key, h.salt[:], []byte(hkdfInfo)), fileKey); err != nil {
+		return nil, err
+	}
+
+	return fileKey, nil
+}
+
+func newStream(key []byte, h *header, ad []byte) (*stream, error) {
+	fileKey, err := deriveKey(key, h)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(fileKey)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	copy(nonce, h.noncePrefix[:])
+
+	return &stream{
+		aead:  aead,
+		ad:    ad,
+		nonce: nonce,
+	}, nil
+}
+
+func (s *stream) next(final bool) ([]byte, error) {
+	if s.index > math.MaxUint32 {
+		return nil, errors.New("too many chunks")
+	}
+
+	binary.BigEndian.PutUint32(s.nonce[noncePrefixSize:], uint32(s.index))
+
+	s.nonce[len(s.nonce)-1] = 0
+	if final {
+		s.nonce[len(s.nonce)-1] = 1
+	}
+
+	s.index++
+
+	return s.nonce, nil
+}
+
+func (s *stream) seal(dst, plaintext []byte, final bool) ([]byte, error) {
+	nonce, err := s.next(final)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.aead.Seal(dst, nonce, plaintext, s.ad), nil
+}
+
+func (s *stream) open(dst, ciphertext []byte, final bool) ([]byte, error) {
+	nonce, err := s.next(final)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := s.aead.Open(dst, nonce, ciphertext, s.ad)
+	if err != nil {
+		return nil, ErrAuthentication
+	}
+
+	return b, nil
+}
@@ -0,0 +1,109 @@
+package encfiles
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/puellanivis/breton/lib/files"
+)
+
+// KeyProvider provides the keys used to encrypt and decrypt content by their key IDs.
+type KeyProvider interface {
+	// Key returns an AES key of 16, 24, or 32 bytes for the given key ID.
+	Key(ctx context.Context, id string) ([]byte, error)
+}
+
+// KeyURLs is a KeyProvider that reads each key from the files URL that its key ID maps to.
+//
+// The content at the URL may be the key encoded in hex or base64, or the raw bytes of the key.
+// As raw bytes cannot be told apart from text, raw bytes are only accepted if they are not all printable text.
+type KeyURLs map[string]string
+
+// Key implements KeyProvider.
+func (m KeyURLs) Key(ctx context.Context, id string) ([]byte, error) {
+	url, ok := m[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", id)
+	}
+
+	b, err := files.Read(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := parseKey(b)
+	if err != nil {
+		return nil, files.PathError("key", url, err)
+	}
+
+	return key, nil
+}
+
+var errInvalidKey = errors.New("key must be 16, 24, or 32 bytes")
+
+func validKeySize(n int) bool {
+	switch n {
+	case 16, 24, 32:
+		return true
+	}
+
+	return false
+}
+
+// isText returns true, if b is only printable ASCII and whitespace.
+func isText(b []byte) bool {
+	for _, c := range b {
+		switch {
+		case c == '\t', c == '\n', c == '\r':
+		case c < ' ', c > '~':
+			return false
+		}
+	}
+
+	return true
+}
+
+// parseKey returns the key from the key encoded in hex or base64, or else from the raw bytes of the key.
+//
+// The text decodings are tried first, so that a key encoded as text is never taken as the raw bytes of its encoding.
+// Raw bytes are then only accepted if they are not text, which a random key almost never is.
+func parseKey(b []byte) ([]byte, error) {
+	s := string(bytes.TrimSpace(b))
+
+	if key, err := hex.DecodeString(s); err == nil && validKeySize(len(key)) {
+		return key, nil
+	}
+
+	if key, err := base64.StdEncoding.DecodeString(s); err == nil && validKeySize(len(key)) {
+		return key, nil
+	}
+
+	if validKeySize(len(b)) && !isText(b) {
+		return b, nil
+	}
+
+	return nil, errInvalidKey
+}
+
+// getKey returns the key with the given ID from the KeyProvider in the Context.
+func getKey(ctx context.Context, id string) ([]byte, error) {
+	p, ok := GetKeyProvider(ctx)
+	if !ok {
+		return nil, errors.New("no key provider")
+	}
+
+	key, err := p.Key(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !validKeySize(len(key)) {
+		return nil, errInvalidKey
+	}
+
+	return key, nil
+}
@@ -0,0 +1,144 @@
+package encfiles
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/puellanivis/breton/lib/files"
+	"github.com/puellanivis/breton/lib/files/wrapper"
+)
+
+type reader struct {
+	*wrapper.Info
+
+	r files.Reader
+	s *stream
+
+	hdrSize int64
+
+	frame []byte
+	buf   []byte
+	off   int64
+	done  bool
+	err   error
+}
+
+func newReader(ctx context.Context, uri *url.URL, r files.Reader) (*reader, error) {
+	h, hdr, err := readHeader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := getKey(ctx, h.keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := newStream(key, h, hdr)
+	if err != nil {
+		return nil, err
+	}
+
+	frameSize := h.chunkSize + s.aead.Overhead()
+
+	// The size of the plaintext is known from the size of the encrypted content,
+	// as every frame but the final frame is full, and the final frame is always present.
+	var size int64
+	if fi, err := r.Stat(); err == nil && fi.Mode().IsRegular() && fi.Size() > int64(len(hdr)) {
+		sz := fi.Size() - int64(len(hdr))
+		frames := sz/int64(frameSize) + 1
+
+		size = sz - frames*int64(s.aead.Overhead())
+		if size < 0 {
+			size = 0
+		}
+	}
+
+	return &reader{
+		Info: wrapper.NewInfo(uri, int(size), time.Now()),
+
+		r: r,
+		s: s,
+
+		hdrSize: int64(len(hdr)),
+
+		frame: make([]byte, frameSize),
+	}, nil
+}
+
+// fill reads and decrypts the next frame.
+func (r *reader) fill() error {
+	n, err := io.ReadFull(r.r, r.frame)
+
+	switch err {
+	case nil:
+		r.buf, err = r.s.open(r.frame[:0], r.frame, false)
+		return err
+
+	case io.ErrUnexpectedEOF:
+		// A short frame is the final frame.
+		r.done = true
+
+		r.buf, err = r.s.open(r.frame[:0], r.frame[:n], true)
+		return err
+
+	case io.EOF:
+		// The content ended without a final frame.
+		return ErrTruncated
+	}
+
+	return err
+}
+
+func (r *reader) Read(b []byte) (n int, err error) {
+	for len(r.buf) < 1 {
+		if r.err != nil {
+			return 0, r.err
+		}
+
+		if r.done {
+			return 0, io.EOF
+		}
+
+		if err := r.fill(); err != nil {
+			r.err = files.PathError("read", r.Name(), err)
+			return 0, r.err
+		}
+	}
+
+	n = copy(b, r.buf)
+	r.buf = r.buf[n:]
+	r.off += int64(n)
+
+	return n, nil
+}
+
+// Seek only supports finding the current offset, and seeking back to the start.
+func (r *reader) Seek(offset int64, whence int) (int64, error) {
+	switch {
+	case offset == 0 && whence == io.SeekCurrent:
+		return r.off, nil
+
+	case offset == 0 && whence == io.SeekStart:
+		if _, err := r.r.Seek(r.hdrSize, io.SeekStart); err != nil {
+			return r.off, err
+		}
+
+		r.s.index = 0
+		r.buf = nil
+		r.off = 0
+		r.done = false
+		r.err = nil
+
+		return 0, nil
+	}
+
+	return r.off, files.PathError("seek", r.Name(), os.ErrInvalid)
+}
+
+func (r *reader) Close() error {
+	return r.r.Close()
+}
@@ -0,0 +1,145 @@
+package encfiles
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/puellanivis/breton/lib/files"
+	"github.com/puellanivis/breton/lib/files/wrapper"
+)
+
+type writer struct {
+	*wrapper.Info
+
+	mu sync.Mutex
+
+	w files.Writer
+	s *stream
+
+	chunkSize int
+	buf       []byte
+	out       []byte
+
+	closed bool
+	err    error
+}
+
+func newWriter(ctx context.Context, uri *url.URL, w files.Writer, chunkSize int) (*writer, error) {
+	id, _ := GetKeyID(ctx)
+
+	key, err := getKey(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	h, err := newHeader(id, chunkSize)
+	if err != nil {
+		return nil, err
+	}
+
+	hdr := h.marshal()
+
+	s, err := newStream(key, h, hdr)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(hdr); err != nil {
+		return nil, err
+	}
+
+	return &writer{
+		Info: wrapper.NewInfo(uri, 0, time.Now()),
+
+		w: w,
+		s: s,
+
+		chunkSize: chunkSize,
+		buf:       make([]byte, 0, chunkSize),
+		out:       make([]byte, 0, chunkSize+s.aead.Overhead()),
+	}, nil
+}
+
+// flush encrypts and writes the buffered chunk.
+func (w *writer) flush(final bool) error {
+	out, err := w.s.seal(w.out[:0], w.buf, final)
+	if err != nil {
+		return err
+	}
+
+	w.buf = w.buf[:0]
+
+	_, err = w.w.Write(out)
+	return err
+}
+
+func (w *writer) Write(b []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return 0, files.PathError("write", w.Name(), os.ErrClosed)
+	}
+
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	for len(b) > 0 {
+		l := copy(w.buf[len(w.buf):cap(w.buf)], b)
+		w.buf = w.buf[:len(w.buf)+l]
+		b = b[l:]
+		n += l
+
+		// Only a short chunk may be the final chunk, so a full chunk can always be written.
+		if len(w.buf) == w.chunkSize {
+			if err := w.flush(false); err != nil {
+				w.err = files.PathError("write", w.Name(), err)
+				return n, w.err
+			}
+		}
+	}
+
+	w.Info.SetSize(int(w.Info.Size()) + n)
+
+	return n, nil
+}
+
+// Sync syncs the underlying files.Writer.
+// Content written since the last full chunk is not written until Close, as only the final chunk may be short.
+func (w *writer) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return files.PathError("sync", w.Name(), os.ErrClosed)
+	}
+
+	return w.w.Sync()
+}
+
+func (w *writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	err := w.err
+	if err == nil {
+		if err = w.flush(true); err != nil {
+			err = files.PathError("close", w.Name(), err)
+		}
+	}
+
+	if err2 := w.w.Close(); err == nil {
+		err = err2
+	}
+
+	return err
+}
@@ -0,0 +1,112 @@
+// Package envfiles implements the "env:" and "secret:" URL schemes,
+// which read configuration from environment variables, and from files in mounted secret directories.
+//
+// Both schemes are read-only, and the content is read completely when opened, so that Stat reports its size.
+// The names and errors of the files only ever refer to the URL, and never include the value read.
+package envfiles
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/puellanivis/breton/lib/files"
+	"github.com/puellanivis/breton/lib/files/wrapper"
+)
+
+// reader is a files.Reader over a value that should not be revealed,
+// so that even formatting it with fmt only shows its name.
+type reader struct {
+	*wrapper.Reader
+}
+
+func newReader(b []byte, uri *url.URL) *reader {
+	return &reader{
+		Reader: wrapper.NewReaderFromBytes(b, uri, time.Now()),
+	}
+}
+
+func (r *reader) String() string {
+	return r.Name()
+}
+
+func (r *reader) GoString() string {
+	return r.Name()
+}
+
+type envHandler struct{}
+
+func init() {
+	files.RegisterScheme(&envHandler{}, "env")
+}
+
+// getName returns the name given in the opaque, or path part of the URL.
+func getName(uri *url.URL) (string, error) {
+	if uri.Host != "" || uri.User != nil {
+		return "", os.ErrInvalid
+	}
+
+	name := uri.Path
+	if name == "" {
+		name = uri.Opaque
+		if n, err := url.PathUnescape(name); err == nil {
+			name = n
+		}
+	}
+
+	return name, nil
+}
+
+func (h *envHandler) Open(ctx context.Context, uri *url.URL) (files.Reader, error) {
+	name, err := getName(uri)
+	if err != nil {
+		return nil, files.PathError("open", uri.String(), err)
+	}
+
+	if name == "" {
+		return nil, files.PathError("open", uri.String(), os.ErrInvalid)
+	}
+
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return nil, files.PathError("open", uri.String(), os.ErrNotExist)
+	}
+
+	return newReader([]byte(value), uri), nil
+}
+
+func (h *envHandler) Create(ctx context.Context, uri *url.URL) (files.Writer, error) {
+	return nil, files.PathError("create", uri.String(), os.ErrInvalid)
+}
+
+// List returns the names of all the environment variables, as "env:" lists all of them.
+func (h *envHandler) List(ctx context.Context, uri *url.URL) ([]os.FileInfo, error) {
+	name, err := getName(uri)
+	if err != nil || name != "" {
+		return nil, files.PathError("readdir", uri.String(), files.ErrNotDirectory)
+	}
+
+	var infos []os.FileInfo
+
+	for _, kv := range os.Environ() {
+		key, value, _ := strings.Cut(kv, "=")
+		if key == "" {
+			// Windows has special variables like "=C:", which are not real names.
+			continue
+		}
+
+		fi := wrapper.NewInfo(nil, len(value), time.Now())
+		fi.SetName(key)
+
+		infos = append(infos, fi)
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name() < infos[j].Name()
+	})
+
+	return infos, nil
+}
@@ -0,0 +1,164 @@
+package envfiles
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/puellanivis/breton/lib/files"
+)
+
+const testValue = "hunter2"
+
+func TestEnv(t *testing.T) {
+	t.Setenv("ENVFILES_TEST", testValue)
+
+	ctx := context.Background()
+
+	r, err := files.Open(ctx, "env:ENVFILES_TEST")
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	defer r.Close()
+
+	if name := r.Name(); name != "env:ENVFILES_TEST" {
+		t.Errorf("got name %q, expected %q", name, "env:ENVFILES_TEST")
+	}
+
+	fi, err := r.Stat()
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if fi.Size() != int64(len(testValue)) {
+		t.Errorf("got size %d, expected %d", fi.Size(), len(testValue))
+	}
+
+	for _, s := range []string{fmt.Sprint(r), fmt.Sprintf("%+v", r), fmt.Sprintf("%#v", r)} {
+		if strings.Contains(s, testValue) {
+			t.Errorf("formatted reader %q reveals the value", s)
+		}
+	}
+
+	b, err := files.ReadFrom(r)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if string(b) != testValue {
+		t.Errorf("got %q, expected %q", b, testValue)
+	}
+
+	if _, err := files.Read(ctx, "env:ENVFILES_TEST_MISSING"); !os.IsNotExist(err) {
+		t.Errorf("got %v, expected a not exist error", err)
+	}
+
+	if _, err := files.Create(ctx, "env:ENVFILES_TEST"); err == nil {
+		t.Error("expected an error creating an env: URL, got none")
+	}
+
+	infos, err := files.ReadDir(ctx, "env:")
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	var found bool
+	for _, fi := range infos {
+		if strings.Contains(fi.Name(), testValue) {
+			t.Errorf("listed name %q reveals the value", fi.Name())
+		}
+
+		if fi.Name() == "ENVFILES_TEST" {
+			found = true
+		}
+	}
+
+	if !found {
+		t.Error("ENVFILES_TEST was not listed")
+	}
+}
+
+func TestSecret(t *testing.T) {
+	dir, err := ioutil.TempDir("", "envfiles")
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	defer os.RemoveAll(dir)
+
+	roots := []string{dir + "/a", dir + "/b"}
+	for _, root := range roots {
+		if err := os.MkdirAll(root+"/db", 0700); err != nil {
+			t.Fatal("unexpected error", err)
+		}
+	}
+
+	secrets := map[string]string{
+		dir + "/a/token":       "from-a",
+		dir + "/b/token":       "from-b",
+		dir + "/b/db/password": testValue,
+		dir + "/outside":       "outside",
+	}
+
+	for name, value := range secrets {
+		if err := ioutil.WriteFile(name, []byte(value), 0600); err != nil {
+			t.Fatal("unexpected error", err)
+		}
+	}
+
+	ctx := WithSecretRoots(context.Background(), roots...)
+
+	tests := []struct {
+		url    string
+		expect string
+	}{
+		{"secret:token", "from-a"},
+		{"secret:db/password", testValue},
+		{"secret:/db/password", testValue},
+	}
+
+	for _, tt := range tests {
+		r, err := files.Open(ctx, tt.url)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.url, err)
+		}
+
+		if r.Name() != tt.url {
+			t.Errorf("got name %q, expected %q", r.Name(), tt.url)
+		}
+
+		b, err := files.ReadFrom(r)
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+
+		if string(b) != tt.expect {
+			t.Errorf("%s: got %q, expected %q", tt.url, b, tt.expect)
+		}
+	}
+
+	_, err = files.Read(ctx, "secret:../outside")
+	if !os.IsNotExist(err) {
+		t.Errorf("got %v, expected a not exist error for a secret outside of the roots", err)
+	}
+
+	if strings.Contains(err.Error(), dir) {
+		t.Errorf("error %q reveals the secret roots", err)
+	}
+
+	infos, err := files.ReadDir(ctx, "secret:")
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	var names []string
+	for _, fi := range infos {
+		names = append(names, fi.Name())
+	}
+
+	if got, expect := strings.Join(names, ","), "db,token"; got != expect {
+		t.Errorf("got listing %q, expected %q", got, expect)
+	}
+}
@@ -0,0 +1,131 @@
+package envfiles
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/puellanivis/breton/lib/files"
+	"github.com/puellanivis/breton/lib/files/wrapper"
+)
+
+// DefaultSecretRoots are the directories searched for secrets, if none are given with WithSecretRoots.
+var DefaultSecretRoots = []string{
+	"/run/secrets",
+}
+
+type keySecretRoots struct{}
+
+// WithSecretRoots returns a Context that includes the directories that the "secret:" scheme searches for secrets, in order.
+func WithSecretRoots(ctx context.Context, roots ...string) context.Context {
+	return context.WithValue(ctx, keySecretRoots{}, roots)
+}
+
+// GetSecretRoots returns the directories searched for secrets for the given Context.
+func GetSecretRoots(ctx context.Context) []string {
+	if roots, ok := ctx.Value(keySecretRoots{}).([]string); ok {
+		return roots
+	}
+
+	return DefaultSecretRoots
+}
+
+type secretHandler struct{}
+
+func init() {
+	files.RegisterScheme(&secretHandler{}, "secret")
+}
+
+// secretPath returns the path of the secret relative to a secret root,
+// which cannot escape the root.
+func secretPath(uri *url.URL) (string, error) {
+	name, err := getName(uri)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.FromSlash(path.Clean("/" + name)), nil
+}
+
+func (h *secretHandler) Open(ctx context.Context, uri *url.URL) (files.Reader, error) {
+	name, err := secretPath(uri)
+	if err != nil {
+		return nil, files.PathError("open", uri.String(), err)
+	}
+
+	for _, root := range GetSecretRoots(ctx) {
+		b, err := ioutil.ReadFile(filepath.Join(root, name))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+
+			// Report only the cause, so that the error does not reveal which root holds the secret.
+			var perr *os.PathError
+			if errors.As(err, &perr) {
+				err = perr.Err
+			}
+
+			return nil, files.PathError("open", uri.String(), err)
+		}
+
+		return newReader(b, uri), nil
+	}
+
+	return nil, files.PathError("open", uri.String(), os.ErrNotExist)
+}
+
+func (h *secretHandler) Create(ctx context.Context, uri *url.URL) (files.Writer, error) {
+	return nil, files.PathError("create", uri.String(), os.ErrInvalid)
+}
+
+// List returns the secrets in the directory in all of the secret roots.
+// Where the same name is in more than one root, only the first is listed, as only it can be opened.
+func (h *secretHandler) List(ctx context.Context, uri *url.URL) ([]os.FileInfo, error) {
+	name, err := secretPath(uri)
+	if err != nil {
+		return nil, files.PathError("readdir", uri.String(), err)
+	}
+
+	seen := make(map[string]bool)
+	var infos []os.FileInfo
+	var found bool
+
+	for _, root := range GetSecretRoots(ctx) {
+		entries, err := ioutil.ReadDir(filepath.Join(root, name))
+		if err != nil {
+			continue
+		}
+		found = true
+
+		for _, fi := range entries {
+			if seen[fi.Name()] {
+				continue
+			}
+			seen[fi.Name()] = true
+
+			info := wrapper.NewInfo(nil, int(fi.Size()), fi.ModTime())
+			info.SetName(fi.Name())
+			if fi.IsDir() {
+				_ = info.Chmod(os.ModeDir | 0755)
+			}
+
+			infos = append(infos, info)
+		}
+	}
+
+	if !found {
+		return nil, files.PathError("readdir", uri.String(), os.ErrNotExist)
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name() < infos[j].Name()
+	})
+
+	return infos, nil
+}
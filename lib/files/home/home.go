@@ -82,3 +82,12 @@ func (h *handler) List(ctx context.Context, uri *url.URL) ([]os.FileInfo, error)
 
 	return ioutil.ReadDir(filename)
 }
+
+func (h *handler) MkdirAll(ctx context.Context, uri *url.URL, perm os.FileMode) error {
+	filename, err := Filename(uri)
+	if err != nil {
+		return files.PathError("mkdir", uri.String(), err)
+	}
+
+	return os.MkdirAll(filename, perm)
+}
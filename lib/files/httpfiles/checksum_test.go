@@ -0,0 +1,75 @@
+package httpfiles
+
+import (
+	"context"
+	"crypto"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/puellanivis/breton/lib/files"
+)
+
+func TestChecksum(t *testing.T) {
+	data := []byte("hello world")
+	md5sum := md5.Sum(data)
+	sha256sum := sha256.Sum256(data)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/digest":
+			w.Header().Set("Digest", "MD5=AAAAAAAAAAAAAAAAAAAAAA==, SHA-256="+base64.StdEncoding.EncodeToString(sha256sum[:]))
+		case "/content-md5":
+			w.Header().Set("Content-MD5", base64.StdEncoding.EncodeToString(md5sum[:]))
+		}
+
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+
+	r, err := files.Open(ctx, srv.URL+"/content-md5", files.WithReadChecksum(crypto.MD5, nil))
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if _, err := files.ReadFrom(r); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	r, err = files.Open(ctx, srv.URL+"/digest")
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	defer r.Close()
+
+	cs, ok := r.(files.Checksummer)
+	if !ok {
+		t.Fatalf("%T does not implement files.Checksummer", r)
+	}
+
+	sum, err := cs.Checksum(crypto.SHA256)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if string(sum) != string(sha256sum[:]) {
+		t.Errorf("got %x, expected %x", sum, sha256sum)
+	}
+
+	if _, err := cs.Checksum(crypto.SHA1); !errors.Is(err, files.ErrNotSupported) {
+		t.Errorf("got %v, expected files.ErrNotSupported", err)
+	}
+
+	// The MD5 in the Digest header is wrong.
+	var merr *files.ChecksumMismatchError
+	if _, err := files.Copy(ctx, ioutil.Discard, r, files.WithChecksum(crypto.MD5, nil)); !errors.As(err, &merr) {
+		t.Errorf("got %v, expected a *files.ChecksumMismatchError", err)
+	}
+}
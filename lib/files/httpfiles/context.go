@@ -15,9 +15,19 @@ func WithClient(ctx context.Context, cl *http.Client) context.Context {
 	return context.WithValue(ctx, clientKey{}, cl)
 }
 
-func getClient(ctx context.Context) (*http.Client, bool) {
+// getClient returns the http.Client attached to the Context, or http.DefaultClient if there is none.
+// If a tls.Config has been attached to the Context, then the returned http.Client will use it.
+func getClient(ctx context.Context) *http.Client {
 	cl, ok := ctx.Value(clientKey{}).(*http.Client)
-	return cl, ok
+	if !ok || cl == nil {
+		cl = http.DefaultClient
+	}
+
+	if v, ok := getTLSConfigValue(ctx); ok {
+		return v.client(cl)
+	}
+
+	return cl
 }
 
 // WithUserAgent attaches an string to the Context, that will be used by this library as the User-Agent in all headers
@@ -2,7 +2,6 @@
 package httpfiles
 
 import (
-	"context"
 	"errors"
 	"net/http"
 	"net/url"
@@ -41,10 +40,15 @@ func elideDefaultPort(uri *url.URL) *url.URL {
 	return uri
 }
 
+// ErrNotModified is returned when a conditional request receives a 304 Not Modified response.
+var ErrNotModified = errors.New("not modified")
+
 func getErr(resp *http.Response) error {
 	switch resp.StatusCode {
 	case http.StatusOK, http.StatusNoContent:
 		return nil
+	case http.StatusNotModified:
+		return ErrNotModified
 	case http.StatusUnauthorized, http.StatusForbidden:
 		return os.ErrPermission
 	case http.StatusNotFound:
@@ -53,7 +57,3 @@ func getErr(resp *http.Response) error {
 
 	return errors.New(resp.Status)
 }
-
-func (h *handler) List(ctx context.Context, uri *url.URL) ([]os.FileInfo, error) {
-	return nil, files.PathError("readdir", uri.String(), os.ErrInvalid)
-}
@@ -0,0 +1,400 @@
+package httpfiles
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/puellanivis/breton/lib/files"
+	"github.com/puellanivis/breton/lib/files/wrapper"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// maxListingSize limits how much of an autoindex page we are willing to read.
+const maxListingSize = 16 * 1024 * 1024
+
+// List performs a best-effort directory listing of an autoindex page,
+// as generated by nginx, Apache, lighttpd, and other common webservers.
+//
+// Only URLs with a path ending in a "/" are considered directories,
+// any other URL, or any page that does not look like an autoindex page,
+// will return an error wrapping files.ErrNotDirectory.
+func (h *handler) List(ctx context.Context, uri *url.URL) ([]os.FileInfo, error) {
+	uri = elideDefaultPort(uri)
+
+	// The request strips any credentials from the URL, so use its URL for names from here on.
+	req := newHTTPRequest(http.MethodGet, uri)
+	req = req.WithContext(ctx)
+	uri = req.URL
+
+	if !strings.HasSuffix(uri.Path, "/") {
+		return nil, &os.PathError{
+			Op:   "readdir",
+			Path: uri.String(),
+			Err:  files.ErrNotDirectory,
+		}
+	}
+
+	cl := getClient(ctx)
+
+	if ua, ok := getUserAgent(ctx); ok {
+		req.Header.Set("User-Agent", ua)
+	}
+	req.Header.Set("Accept", "text/html, application/json;q=0.9, */*;q=0.1")
+
+	resp, err := cl.Do(req)
+	if err != nil {
+		return nil, &os.PathError{
+			Op:   "readdir",
+			Path: uri.String(),
+			Err:  err,
+		}
+	}
+	defer resp.Body.Close()
+
+	if err := getErr(resp); err != nil {
+		return nil, &os.PathError{
+			Op:   "readdir",
+			Path: uri.String(),
+			Err:  err,
+		}
+	}
+
+	// We might have been redirected, so resolve everything against where we actually ended up.
+	base := resp.Request.URL
+
+	var infos []os.FileInfo
+
+	ctype, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch {
+	case ctype == "application/json" || strings.HasSuffix(ctype, "+json"):
+		infos, err = parseJSONIndex(base, io.LimitReader(resp.Body, maxListingSize))
+
+	case ctype == "text/html" || ctype == "application/xhtml+xml":
+		infos, err = parseHTMLIndex(base, io.LimitReader(resp.Body, maxListingSize))
+
+	default:
+		err = files.ErrNotDirectory
+	}
+
+	if err != nil {
+		return nil, &os.PathError{
+			Op:   "readdir",
+			Path: uri.String(),
+			Err:  err,
+		}
+	}
+
+	return infos, nil
+}
+
+// childName returns the name of the entry that href references, if it is a direct child of base.
+func childName(base *url.URL, href string) (name string, isDir bool, ok bool) {
+	ref, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return "", false, false
+	}
+
+	// Sorting links, like Apache’s "?C=N;O=D" only have a query.
+	if ref.Path == "" && ref.Opaque == "" {
+		return "", false, false
+	}
+
+	uri := base.ResolveReference(ref)
+	if uri.Scheme != base.Scheme || uri.Host != base.Host {
+		return "", false, false
+	}
+
+	if !strings.HasPrefix(uri.Path, base.Path) {
+		return "", false, false
+	}
+
+	name = strings.TrimPrefix(uri.Path, base.Path)
+	if strings.HasSuffix(name, "/") {
+		name, isDir = strings.TrimSuffix(name, "/"), true
+	}
+
+	if name == "" || name == "." || name == ".." || strings.Contains(name, "/") {
+		return "", false, false
+	}
+
+	return name, isDir, true
+}
+
+func newEntry(base *url.URL, name string, isDir bool, size int64, mtime time.Time) os.FileInfo {
+	elem := name
+	if isDir {
+		elem += "/"
+	}
+
+	uri := base.ResolveReference(&url.URL{
+		Path: path.Join(base.Path, elem),
+	})
+	if isDir && !strings.HasSuffix(uri.Path, "/") {
+		uri.Path += "/"
+	}
+
+	fi := wrapper.NewInfo(uri, int(size), mtime)
+
+	if isDir {
+		_ = fi.Chmod(os.ModeDir | 0755)
+	}
+
+	return fi
+}
+
+// jsonEntry is the format used by nginx `autoindex_format json;`.
+type jsonEntry struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	MTime string `json:"mtime"`
+	Size  *int64 `json:"size"`
+}
+
+func parseJSONIndex(base *url.URL, r io.Reader) ([]os.FileInfo, error) {
+	var entries []jsonEntry
+
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return nil, files.ErrNotDirectory
+	}
+
+	infos := make([]os.FileInfo, 0, len(entries))
+
+	for _, e := range entries {
+		if e.Name == "" || e.Name == "." || e.Name == ".." || strings.Contains(e.Name, "/") {
+			continue
+		}
+
+		var size int64
+		if e.Size != nil {
+			size = *e.Size
+		}
+
+		var mtime time.Time
+		if e.MTime != "" {
+			if t, err := http.ParseTime(e.MTime); err == nil {
+				mtime = t
+			}
+		}
+
+		infos = append(infos, newEntry(base, e.Name, e.Type == "directory", size, mtime))
+	}
+
+	return infos, nil
+}
+
+// indexEntry is an autoindex entry being collected from an HTML page.
+type indexEntry struct {
+	name  string
+	isDir bool
+
+	inAnchor bool
+	tail     strings.Builder
+}
+
+func parseHTMLIndex(base *url.URL, r io.Reader) ([]os.FileInfo, error) {
+	z := html.NewTokenizer(r)
+
+	var infos []os.FileInfo
+	var cur *indexEntry
+
+	seen := make(map[string]bool)
+
+	flush := func() {
+		if cur == nil {
+			return
+		}
+
+		e := cur
+		cur = nil
+
+		if seen[e.name] {
+			return
+		}
+		seen[e.name] = true
+
+		mtime, size := parseIndexTail(e.tail.String())
+		if e.isDir {
+			size = 0
+		}
+
+		infos = append(infos, newEntry(base, e.name, e.isDir, size, mtime))
+	}
+
+	var looksLikeIndex, inTitle bool
+
+	for {
+		tt := z.Next()
+
+		switch tt {
+		case html.ErrorToken:
+			if err := z.Err(); err != io.EOF {
+				return nil, err
+			}
+
+			flush()
+
+			if !looksLikeIndex && len(infos) < 1 {
+				return nil, files.ErrNotDirectory
+			}
+
+			return infos, nil
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tag, hasAttr := z.TagName()
+
+			switch atom.Lookup(tag) {
+			case atom.A:
+				var href string
+				for hasAttr {
+					var key, val []byte
+					key, val, hasAttr = z.TagAttr()
+
+					if string(key) == "href" {
+						href = string(val)
+					}
+				}
+
+				name, isDir, ok := childName(base, href)
+
+				if cur != nil && ok && cur.name == name {
+					// Icons are sometimes links to the same entry as the name.
+					cur.inAnchor = true
+					continue
+				}
+
+				flush()
+
+				if ok {
+					cur = &indexEntry{
+						name:     name,
+						isDir:    isDir,
+						inAnchor: true,
+					}
+				}
+
+			case atom.Tr, atom.Li, atom.Br, atom.Hr:
+				flush()
+
+			case atom.Title, atom.H1:
+				inTitle = true
+			}
+
+		case html.EndTagToken:
+			tag, _ := z.TagName()
+
+			switch atom.Lookup(tag) {
+			case atom.A:
+				if cur != nil {
+					cur.inAnchor = false
+				}
+
+			case atom.Tr, atom.Li, atom.Pre, atom.Table, atom.Ul:
+				flush()
+
+			case atom.Title, atom.H1:
+				inTitle = false
+			}
+
+		case html.TextToken:
+			text := z.Text()
+
+			if inTitle && strings.HasPrefix(strings.TrimSpace(string(text)), "Index of") {
+				looksLikeIndex = true
+			}
+
+			if cur == nil || cur.inAnchor {
+				continue
+			}
+
+			cur.tail.Write(text)
+			cur.tail.WriteByte(' ')
+
+			if i := strings.IndexByte(cur.tail.String(), '\n'); i >= 0 && strings.TrimSpace(cur.tail.String()[:i]) != "" {
+				// In a <pre> formatted listing, each entry ends at the end of the line.
+				flush()
+			}
+		}
+	}
+}
+
+// indexTimeLayouts are the formats of modification times commonly found in autoindex pages.
+var indexTimeLayouts = []string{
+	"02-Jan-2006 15:04",    // nginx, and Apache pre-2.4
+	"02-Jan-2006 15:04:05", // lighttpd
+	"2006-01-02 15:04",     // Apache 2.4
+	"2006-01-02 15:04:05",
+	"2006-Jan-02 15:04:05", // lighttpd
+}
+
+// parseIndexTail parses the text following a link in an autoindex page for a modification time and size.
+//
+// Formats are like: "01-Jan-2020 12:34    1234", or "2020-01-01 12:34  1.2K  ".
+func parseIndexTail(tail string) (mtime time.Time, size int64) {
+	fields := strings.Fields(tail)
+
+	for i := 0; i+1 < len(fields); i++ {
+		s := fields[i] + " " + fields[i+1]
+
+		for _, layout := range indexTimeLayouts {
+			t, err := time.Parse(layout, s)
+			if err != nil {
+				continue
+			}
+
+			mtime = t
+
+			if i+2 < len(fields) {
+				size = parseIndexSize(fields[i+2])
+			}
+
+			return mtime, size
+		}
+	}
+
+	return mtime, size
+}
+
+var indexSizeScales = map[byte]float64{
+	'K': 1 << 10,
+	'k': 1 << 10,
+	'M': 1 << 20,
+	'G': 1 << 30,
+	'T': 1 << 40,
+}
+
+// parseIndexSize parses a size field, which may be human-readable: "1234", "1.2K", "-".
+func parseIndexSize(s string) int64 {
+	if s == "" || s == "-" {
+		return 0
+	}
+
+	scale := 1.0
+	if f := indexSizeScales[s[len(s)-1]]; f > 0 {
+		scale = f
+		s = s[:len(s)-1]
+	}
+
+	if scale == 1 {
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n
+		}
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f < 0 {
+		return 0
+	}
+
+	return int64(f * scale)
+}
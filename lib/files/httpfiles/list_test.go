@@ -0,0 +1,181 @@
+package httpfiles
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/puellanivis/breton/lib/files"
+)
+
+const nginxIndex = `<html>
+<head><title>Index of /pub/</title></head>
+<body>
+<h1>Index of /pub/</h1><hr><pre><a href="../">../</a>
+<a href="releases/">releases/</a>                                          02-Mar-2021 10:15                   -
+<a href="README.txt">README.txt</a>                                         01-Jan-2020 12:34                1234
+<a href="hello%20world.bin">hello world.bin</a>                                    15-Jun-2022 08:00             1048576
+</pre><hr></body>
+</html>
+`
+
+const apacheIndex = `<!DOCTYPE HTML PUBLIC "-//W3C//DTD HTML 3.2 Final//EN">
+<html>
+ <head>
+  <title>Index of /pub</title>
+ </head>
+ <body>
+<h1>Index of /pub</h1>
+  <table>
+   <tr><th valign="top"><img src="/icons/blank.gif" alt="[ICO]"></th><th><a href="?C=N;O=D">Name</a></th><th><a href="?C=M;O=A">Last modified</a></th><th><a href="?C=S;O=A">Size</a></th><th><a href="?C=D;O=A">Description</a></th></tr>
+   <tr><th colspan="5"><hr></th></tr>
+<tr><td valign="top"><img src="/icons/back.gif" alt="[PARENTDIR]"></td><td><a href="/">Parent Directory</a></td><td>&nbsp;</td><td align="right">  - </td><td>&nbsp;</td></tr>
+<tr><td valign="top"><img src="/icons/folder.gif" alt="[DIR]"></td><td><a href="releases/">releases/</a></td><td align="right">2021-03-02 10:15  </td><td align="right">  - </td><td>&nbsp;</td></tr>
+<tr><td valign="top"><img src="/icons/text.gif" alt="[TXT]"></td><td><a href="README.txt">README.txt</a></td><td align="right">2020-01-01 12:34  </td><td align="right">1.5K</td><td>&nbsp;</td></tr>
+   <tr><th colspan="5"><hr></th></tr>
+</table>
+</body></html>
+`
+
+const jsonIndex = `[
+{ "name":"releases", "type":"directory", "mtime":"Tue, 02 Mar 2021 10:15:00 GMT" },
+{ "name":"README.txt", "type":"file", "mtime":"Wed, 01 Jan 2020 12:34:00 GMT", "size":1234 }
+]`
+
+type expectedEntry struct {
+	name  string
+	isDir bool
+	size  int64
+	mtime time.Time
+}
+
+func newIndexServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/nginx/pub/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(nginxIndex))
+	})
+
+	mux.HandleFunc("/apache/pub/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html;charset=UTF-8")
+		w.Write([]byte(apacheIndex))
+	})
+
+	mux.HandleFunc("/json/pub/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(jsonIndex))
+	})
+
+	mux.HandleFunc("/plain/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("not a directory"))
+	})
+
+	mux.HandleFunc("/page/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><title>Welcome</title></head><body><a href="https://example.com/">elsewhere</a></body></html>`))
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func testList(t *testing.T, srv *httptest.Server, path string, expected []expectedEntry) {
+	t.Helper()
+
+	uri, err := url.Parse(srv.URL + path)
+	if err != nil {
+		t.Fatal("unexpected error parsing URL", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	infos, err := (&handler{}).List(ctx, uri)
+	if err != nil {
+		t.Fatalf("unexpected error listing %s: %v", path, err)
+	}
+
+	if len(infos) != len(expected) {
+		for _, info := range infos {
+			t.Log(info.Name(), info.Size(), info.ModTime(), info.IsDir())
+		}
+		t.Fatalf("got %d entries listing %s, expected %d", len(infos), path, len(expected))
+	}
+
+	for i, e := range expected {
+		info := infos[i]
+
+		name := srv.URL + path + e.name
+		if e.isDir {
+			name += "/"
+		}
+
+		if got := info.Name(); got != name {
+			t.Errorf("entry %d: got name %q, expected %q", i, got, name)
+		}
+
+		if got := info.IsDir(); got != e.isDir {
+			t.Errorf("entry %d: got IsDir() %v, expected %v", i, got, e.isDir)
+		}
+
+		if got := info.Size(); got != e.size {
+			t.Errorf("entry %d: got size %d, expected %d", i, got, e.size)
+		}
+
+		if got := info.ModTime(); !got.Equal(e.mtime) {
+			t.Errorf("entry %d: got mtime %v, expected %v", i, got, e.mtime)
+		}
+	}
+}
+
+func TestListAutoindex(t *testing.T) {
+	srv := newIndexServer(t)
+	defer srv.Close()
+
+	releases := time.Date(2021, time.March, 2, 10, 15, 0, 0, time.UTC)
+	readme := time.Date(2020, time.January, 1, 12, 34, 0, 0, time.UTC)
+
+	testList(t, srv, "/nginx/pub/", []expectedEntry{
+		{name: "releases", isDir: true, mtime: releases},
+		{name: "README.txt", size: 1234, mtime: readme},
+		{name: "hello%20world.bin", size: 1048576, mtime: time.Date(2022, time.June, 15, 8, 0, 0, 0, time.UTC)},
+	})
+
+	testList(t, srv, "/apache/pub/", []expectedEntry{
+		{name: "releases", isDir: true, mtime: releases},
+		{name: "README.txt", size: 1536, mtime: readme},
+	})
+
+	testList(t, srv, "/json/pub/", []expectedEntry{
+		{name: "releases", isDir: true, mtime: releases},
+		{name: "README.txt", size: 1234, mtime: readme},
+	})
+}
+
+func TestListNotDirectory(t *testing.T) {
+	srv := newIndexServer(t)
+	defer srv.Close()
+
+	for _, path := range []string{
+		"/nginx/pub",
+		"/plain/",
+		"/page/",
+	} {
+		uri, err := url.Parse(srv.URL + path)
+		if err != nil {
+			t.Fatal("unexpected error parsing URL", err)
+		}
+
+		_, err = (&handler{}).List(context.Background(), uri)
+		if !errors.Is(err, files.ErrNotDirectory) {
+			t.Errorf("List(%q) returned %v, expected files.ErrNotDirectory", path, err)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package httpfiles
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/puellanivis/breton/lib/files"
+)
+
+// WebDAV methods to make a collection, and to get the properties of a resource, from RFC 4918.
+const (
+	methodMkcol    = "MKCOL"
+	methodPropfind = "PROPFIND"
+)
+
+// propfindResourceType is the body of a PROPFIND that asks only for the type of the resource.
+const propfindResourceType = `<?xml version="1.0" encoding="utf-8"?>
+<D:propfind xmlns:D="DAV:"><D:prop><D:resourcetype/></D:prop></D:propfind>`
+
+// MkdirAll makes the collection at the URL on a WebDAV server, along with any missing parent collections.
+// The permissions are ignored, as WebDAV has no equivalent.
+func (h *handler) MkdirAll(ctx context.Context, uri *url.URL, perm os.FileMode) error {
+	uri = elideDefaultPort(uri)
+
+	return mkcol(ctx, getClient(ctx), uri, 0)
+}
+
+// maxMkcolDepth bounds how many parent collections are made, in case a server never stops reporting a conflict.
+const maxMkcolDepth = 64
+
+func mkcol(ctx context.Context, cl *http.Client, uri *url.URL, depth int) error {
+	// The request strips any credentials from the URL, so use its URL for names from here on.
+	req := newHTTPRequest(methodMkcol, uri)
+	req = req.WithContext(ctx)
+	name := req.URL.String()
+
+	if ua, ok := getUserAgent(ctx); ok {
+		req.Header.Set("User-Agent", ua)
+	}
+
+	resp, err := cl.Do(req)
+	if err != nil {
+		return files.PathError("mkdir", name, err)
+	}
+
+	if err := files.Discard(resp.Body); err != nil {
+		return files.PathError("mkdir", name, err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusCreated:
+		return nil
+
+	case http.StatusMethodNotAllowed:
+		// A WebDAV server only does not allow MKCOL on a resource that already exists,
+		// but any other server does not allow it at all, so confirm that the collection exists.
+		exists, err := isCollection(ctx, cl, uri)
+		if err != nil {
+			return files.PathError("mkdir", name, err)
+		}
+
+		if !exists {
+			return files.PathError("mkdir", name, files.ErrNotSupported)
+		}
+
+		return nil
+
+	case http.StatusConflict:
+		// A parent collection is missing, so make it, and try again.
+		dir := strings.TrimSuffix(uri.Path, "/")
+
+		parent := path.Dir(dir)
+		if parent == dir || depth >= maxMkcolDepth {
+			break
+		}
+
+		parentURL := *uri
+		parentURL.Path = parent + "/"
+		parentURL.RawPath = ""
+
+		if err := mkcol(ctx, cl, &parentURL, depth+1); err != nil {
+			return err
+		}
+
+		return mkcol(ctx, cl, uri, depth+1)
+	}
+
+	if err := getErr(resp); err != nil {
+		return files.PathError("mkdir", name, err)
+	}
+
+	return nil
+}
+
+// isCollection returns true, if a PROPFIND of the URL reports a collection,
+// or if the server does not allow PROPFIND, then if a HEAD of the URL with a trailing slash succeeds.
+func isCollection(ctx context.Context, cl *http.Client, uri *url.URL) (bool, error) {
+	dirURL := *uri
+	if !strings.HasSuffix(dirURL.Path, "/") {
+		dirURL.Path += "/"
+		dirURL.RawPath = ""
+	}
+
+	req := newHTTPRequest(methodPropfind, &dirURL)
+	req = req.WithContext(ctx)
+
+	req.Header.Set("Depth", "0")
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+	req.Body = ioutil.NopCloser(strings.NewReader(propfindResourceType))
+	req.ContentLength = int64(len(propfindResourceType))
+
+	if ua, ok := getUserAgent(ctx); ok {
+		req.Header.Set("User-Agent", ua)
+	}
+
+	resp, err := cl.Do(req)
+	if err != nil {
+		return false, err
+	}
+
+	if resp.StatusCode == http.StatusMultiStatus {
+		defer files.Discard(resp.Body)
+
+		return hasCollection(resp.Body)
+	}
+
+	if err := files.Discard(resp.Body); err != nil {
+		return false, err
+	}
+
+	if resp.StatusCode != http.StatusMethodNotAllowed && resp.StatusCode != http.StatusNotImplemented {
+		return false, nil
+	}
+
+	req = newHTTPRequest(http.MethodHead, &dirURL)
+	req = req.WithContext(ctx)
+
+	if ua, ok := getUserAgent(ctx); ok {
+		req.Header.Set("User-Agent", ua)
+	}
+
+	resp, err = cl.Do(req)
+	if err != nil {
+		return false, err
+	}
+
+	if err := files.Discard(resp.Body); err != nil {
+		return false, err
+	}
+
+	return resp.StatusCode >= 200 && resp.StatusCode < 300, nil
+}
+
+// hasCollection returns true, if the multistatus response of a PROPFIND has a resourcetype of collection.
+func hasCollection(r io.Reader) (bool, error) {
+	dec := xml.NewDecoder(r)
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+
+		if start, ok := tok.(xml.StartElement); ok && start.Name.Space == "DAV:" && start.Name.Local == "collection" {
+			return true, nil
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package httpfiles
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/puellanivis/breton/lib/files"
+)
+
+func TestMkdirAll(t *testing.T) {
+	var mu sync.Mutex
+	collections := map[string]bool{
+		"/": true,
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		dir := strings.TrimSuffix(r.URL.Path, "/")
+
+		switch r.Method {
+		case methodMkcol:
+			// Handled below.
+
+		case methodPropfind:
+			if r.Header.Get("Depth") != "0" || !collections[dir] {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+
+			w.WriteHeader(http.StatusMultiStatus)
+			io.WriteString(w, `<?xml version="1.0" encoding="utf-8"?>
+<D:multistatus xmlns:D="DAV:"><D:response><D:href>`+r.URL.Path+`</D:href>
+<D:propstat><D:prop><D:resourcetype><D:collection/></D:resourcetype></D:prop>
+<D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response></D:multistatus>`)
+			return
+
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		switch {
+		case collections[dir]:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+
+		case !collections[path.Dir(dir)]:
+			w.WriteHeader(http.StatusConflict)
+
+		default:
+			collections[dir] = true
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	defer srv.Close()
+
+	uri, err := url.Parse(srv.URL + "/dav/a/b/")
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if err := (&handler{}).MkdirAll(context.Background(), uri, 0755); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	for _, dir := range []string{"/dav", "/dav/a", "/dav/a/b"} {
+		if !collections[dir] {
+			t.Errorf("collection %s was not made", dir)
+		}
+	}
+
+	// Making a collection that already exists does nothing.
+	if err := (&handler{}).MkdirAll(context.Background(), uri, 0755); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+}
+
+func TestMkdirAllNotWebDAV(t *testing.T) {
+	// A server that is not WebDAV does not allow MKCOL, or PROPFIND, and has no resource at the URL.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			w.WriteHeader(http.StatusNotFound)
+
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer srv.Close()
+
+	uri, err := url.Parse(srv.URL + "/dav/a/")
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if err := (&handler{}).MkdirAll(context.Background(), uri, 0755); !errors.Is(err, files.ErrNotSupported) {
+		t.Errorf("got %v, expected %v", err, files.ErrNotSupported)
+	}
+}
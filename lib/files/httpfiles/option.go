@@ -87,3 +87,40 @@ func WithContentType(contentType string) files.Option {
 		return WithContentType(save), nil
 	}
 }
+
+func withHeaderValues(key string, values []string) files.Option {
+	type headerSetter interface {
+		SetHeader(string, []string) []string
+	}
+
+	return func(f files.File) (files.Option, error) {
+		var save []string
+
+		if r, ok := f.(headerSetter); ok {
+			save = r.SetHeader(key, values)
+		}
+
+		return withHeaderValues(key, save), nil
+	}
+}
+
+// WithHeader returns a files.Option that sets the given header of the
+// underlying HTTP request to the given values, replacing any existing values.
+// If no values are given, then the header is removed from the request.
+func WithHeader(key string, values ...string) files.Option {
+	return withHeaderValues(key, values)
+}
+
+// WithBasicAuth returns a files.Option that sets the Authorization header of the
+// underlying HTTP request to use Basic authentication with the given username and password.
+//
+// Credentials given in the userinfo of an http: or https: URL are already used for Basic authentication.
+func WithBasicAuth(username, password string) files.Option {
+	return WithHeader("Authorization", basicAuth(username, password))
+}
+
+// WithBearerToken returns a files.Option that sets the Authorization header of the
+// underlying HTTP request to use the given Bearer token.
+func WithBearerToken(token string) files.Option {
+	return WithHeader("Authorization", "Bearer "+token)
+}
@@ -0,0 +1,73 @@
+package httpfiles
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCredentialsAndHeaders(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(r.Header.Get("Authorization") + "|" + r.Header.Get("X-Test")))
+	}))
+	defer srv.Close()
+
+	uri, err := url.Parse(srv.URL + "/secret")
+	if err != nil {
+		t.Fatal("unexpected error parsing URL", err)
+	}
+	uri.User = url.UserPassword("user", "hunter2")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	f, err := (&handler{}).Open(ctx, uri)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if name := f.Name(); strings.Contains(name, "user") || strings.Contains(name, "hunter2") {
+		t.Errorf("credentials were not redacted from Name(): %q", name)
+	}
+
+	if _, err := WithHeader("X-Test", "ohai")(f); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	f.Close()
+
+	expected := basicAuth("user", "hunter2") + "|ohai"
+	if got := string(b); got != expected {
+		t.Errorf("got %q, expected %q", got, expected)
+	}
+
+	f, err = (&handler{}).Open(ctx, uri)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if _, err := WithBearerToken("token")(f); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	b, err = ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	f.Close()
+
+	expected = "Bearer token|"
+	if got := string(b); got != expected {
+		t.Errorf("got %q, expected %q", got, expected)
+	}
+}
@@ -3,10 +3,14 @@ package httpfiles
 import (
 	"bytes"
 	"context"
+	"crypto"
+	"crypto/md5"
+	"encoding/base64"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/puellanivis/breton/lib/files"
@@ -19,23 +23,71 @@ type reader struct {
 	info *wrapper.Info
 
 	*request
-	header http.Header
+	header       http.Header
+	uncompressed bool
 
 	err     error
 	loading <-chan struct{}
 }
 
+// Header returns the headers of the HTTP response.
+//
+// If the server responded with an error status, then the headers of that response are returned along with the error.
+// This allows, for example, inspecting the headers of a 304 Not Modified response.
 func (r *reader) Header() (http.Header, error) {
 	for range r.loading {
 	}
 
 	if r.err != nil {
-		return nil, r.err
+		return r.header, r.err
 	}
 
 	return r.header, nil
 }
 
+// digestAlgorithms maps the algorithms of an HTTP Digest header to their hashes.
+var digestAlgorithms = map[string]crypto.Hash{
+	"md5":     crypto.MD5,
+	"sha":     crypto.SHA1,
+	"sha-256": crypto.SHA256,
+	"sha-512": crypto.SHA512,
+}
+
+// Checksum implements files.Checksummer, with the digests given in the Digest or Content-MD5 headers of the HTTP response.
+//
+// If the response was transparently decompressed, then the digests are of the compressed content, and so none are available.
+func (r *reader) Checksum(h crypto.Hash) ([]byte, error) {
+	for range r.loading {
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	if !r.uncompressed {
+		for _, digest := range r.header.Values("Digest") {
+			for _, field := range strings.Split(digest, ",") {
+				alg, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+				if !ok || digestAlgorithms[strings.ToLower(alg)] != h {
+					continue
+				}
+
+				if sum, err := base64.StdEncoding.DecodeString(value); err == nil {
+					return sum, nil
+				}
+			}
+		}
+
+		if h == crypto.MD5 {
+			if sum, err := base64.StdEncoding.DecodeString(r.header.Get("Content-MD5")); err == nil && len(sum) == md5.Size {
+				return sum, nil
+			}
+		}
+	}
+
+	return nil, files.PathError("checksum", r.name, files.ErrNotSupported)
+}
+
 func (r *reader) Stat() (os.FileInfo, error) {
 	for range r.loading {
 	}
@@ -95,13 +147,12 @@ func (r *reader) Close() error {
 func (h *handler) Open(ctx context.Context, uri *url.URL) (files.Reader, error) {
 	uri = elideDefaultPort(uri)
 
-	cl, ok := getClient(ctx)
-	if !ok {
-		cl = http.DefaultClient
-	}
+	cl := getClient(ctx)
 
+	// The request strips any credentials from the URL, so use its URL for names from here on.
 	req := newHTTPRequest(http.MethodGet, uri)
 	req = req.WithContext(ctx)
+	uri = req.URL
 
 	if ua, ok := getUserAgent(ctx); ok {
 		req.Header.Set("User-Agent", ua)
@@ -152,6 +203,7 @@ func (h *handler) Open(ctx context.Context, uri *url.URL) (files.Reader, error)
 		}
 
 		r.header = resp.Header
+		r.uncompressed = resp.Uncompressed
 		uri := resp.Request.URL
 
 		t := time.Now()
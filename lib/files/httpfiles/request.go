@@ -2,6 +2,7 @@ package httpfiles
 
 import (
 	"bytes"
+	"encoding/base64"
 	"io"
 	"io/ioutil"
 	"net/http"
@@ -16,18 +17,40 @@ type request struct {
 	req  *http.Request
 }
 
+// newHTTPRequest returns a new http.Request for the given method and URL.
+//
+// Any credentials in the userinfo of the URL are removed from the URL of the http.Request,
+// and instead set as Basic authentication.
+// This ensures that the credentials do not show up in any name or error derived from the http.Request.URL.
 func newHTTPRequest(method string, uri *url.URL) *http.Request {
+	header := make(http.Header)
+
+	if uri.User != nil {
+		username := uri.User.Username()
+		password, _ := uri.User.Password()
+
+		header.Set("Authorization", basicAuth(username, password))
+
+		uriCopy := *uri
+		uriCopy.User = nil
+		uri = &uriCopy
+	}
+
 	return &http.Request{
 		Method:     method,
 		URL:        uri,
 		Proto:      "HTTP/1.1",
 		ProtoMajor: 1,
 		ProtoMinor: 1,
-		Header:     make(http.Header),
+		Header:     header,
 		Host:       uri.Host,
 	}
 }
 
+func basicAuth(username, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+}
+
 func (r *request) Name() string {
 	return r.name
 }
@@ -50,6 +73,26 @@ func (r *request) SetContentType(contentType string) string {
 	return save
 }
 
+func (r *request) SetHeader(key string, values []string) []string {
+	if r.req.Header == nil {
+		r.req.Header = make(http.Header)
+	}
+
+	save := r.req.Header.Values(key)
+
+	if len(values) < 1 {
+		r.req.Header.Del(key)
+		return save
+	}
+
+	r.req.Header.Del(key)
+	for _, value := range values {
+		r.req.Header.Add(key, value)
+	}
+
+	return save
+}
+
 func (r *request) SetBody(body []byte) []byte {
 	save := r.body
 	r.body = body
@@ -0,0 +1,163 @@
+package httpfiles
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/puellanivis/breton/lib/files"
+)
+
+type tlsConfigKey struct{}
+
+// tlsConfigValue is the tls.Config attached to a Context,
+// along with the http.Clients derived from it, so that connections may be reused between requests.
+//
+// The derived http.Clients live only as long as the Context value does,
+// so attaching a new tls.Config for each request does not accumulate http.Transports.
+type tlsConfigValue struct {
+	conf *tls.Config
+
+	mu      sync.Mutex
+	clients map[*http.Client]*http.Client
+}
+
+// WithTLSConfig attaches a tls.Config to the Context, that will be used by this library for all HTTPS requests.
+//
+// The http.Client attached with WithClient (or http.DefaultClient) must use an *http.Transport,
+// or a nil Transport, for the tls.Config to be applied.
+// The tls.Config given MUST NOT be modified after it has been attached to the Context.
+func WithTLSConfig(ctx context.Context, conf *tls.Config) context.Context {
+	return context.WithValue(ctx, tlsConfigKey{}, &tlsConfigValue{
+		conf: conf,
+	})
+}
+
+func getTLSConfigValue(ctx context.Context) (*tlsConfigValue, bool) {
+	v, ok := ctx.Value(tlsConfigKey{}).(*tlsConfigValue)
+	return v, ok && v.conf != nil
+}
+
+func getTLSConfig(ctx context.Context) (*tls.Config, bool) {
+	v, ok := getTLSConfigValue(ctx)
+	if !ok {
+		return nil, false
+	}
+
+	return v.conf, true
+}
+
+// cloneTLSConfig returns a copy of the tls.Config attached to the Context,
+// or a new empty tls.Config if none is attached.
+func cloneTLSConfig(ctx context.Context) *tls.Config {
+	if conf, ok := getTLSConfig(ctx); ok {
+		return conf.Clone()
+	}
+
+	return new(tls.Config)
+}
+
+// WithRootCAs attaches a tls.Config to the Context that uses the given pool of certificates to verify servers.
+// Any other settings of a tls.Config already attached to the Context are retained.
+func WithRootCAs(ctx context.Context, pool *x509.CertPool) context.Context {
+	conf := cloneTLSConfig(ctx)
+	conf.RootCAs = pool
+
+	return WithTLSConfig(ctx, conf)
+}
+
+// WithClientCertificates attaches a tls.Config to the Context that presents the given certificates to servers.
+// Any other settings of a tls.Config already attached to the Context are retained.
+func WithClientCertificates(ctx context.Context, certs ...tls.Certificate) context.Context {
+	conf := cloneTLSConfig(ctx)
+	conf.Certificates = certs
+
+	return WithTLSConfig(ctx, conf)
+}
+
+// WithServerName attaches a tls.Config to the Context that overrides the server name
+// used for SNI and to verify the hostname on the certificates returned by servers.
+// Any other settings of a tls.Config already attached to the Context are retained.
+func WithServerName(ctx context.Context, name string) context.Context {
+	conf := cloneTLSConfig(ctx)
+	conf.ServerName = name
+
+	return WithTLSConfig(ctx, conf)
+}
+
+// LoadCertPool reads PEM encoded certificates from each of the given filenames, and returns them in a new x509.CertPool.
+//
+// The filenames are read with files.Read, so any scheme supported by lib/files may be used.
+func LoadCertPool(ctx context.Context, filenames ...string) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+
+	for _, filename := range filenames {
+		b, err := files.Read(ctx, filename)
+		if err != nil {
+			return nil, err
+		}
+
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, errors.New("no certificates found in " + filename)
+		}
+	}
+
+	return pool, nil
+}
+
+// LoadX509KeyPair reads and parses a public/private key pair from a pair of files.
+// The files must contain PEM encoded data.
+//
+// The filenames are read with files.Read, so any scheme supported by lib/files may be used.
+func LoadX509KeyPair(ctx context.Context, certFile, keyFile string) (tls.Certificate, error) {
+	cert, err := files.Read(ctx, certFile)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	key, err := files.Read(ctx, keyFile)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.X509KeyPair(cert, key)
+}
+
+// client returns an http.Client that is a copy of the given http.Client,
+// except it uses a Transport with the tls.Config.
+//
+// If the given http.Client does not use an *http.Transport, then it is returned unmodified.
+func (v *tlsConfigValue) client(cl *http.Client) *http.Client {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if derived := v.clients[cl]; derived != nil {
+		return derived
+	}
+
+	rt := cl.Transport
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+
+	tr, ok := rt.(*http.Transport)
+	if !ok {
+		return cl
+	}
+
+	tr = tr.Clone()
+	tr.TLSClientConfig = v.conf
+
+	clCopy := *cl
+	clCopy.Transport = tr
+
+	if v.clients == nil {
+		v.clients = make(map[*http.Client]*http.Client)
+	}
+	v.clients[cl] = &clCopy
+
+	return &clCopy
+}
@@ -0,0 +1,30 @@
+package httpfiles
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestTLSClientReuse(t *testing.T) {
+	ctx := WithServerName(context.Background(), "example.com")
+
+	cl := getClient(ctx)
+	if cl == http.DefaultClient {
+		t.Fatal("expected a client derived for the tls.Config, got http.DefaultClient")
+	}
+
+	if got := getClient(WithUserAgent(ctx, "test")); got != cl {
+		t.Error("expected the derived client to be reused by a child Context")
+	}
+
+	other := getClient(WithServerName(context.Background(), "example.com"))
+	if other == cl {
+		t.Error("expected a separately attached tls.Config to derive its own client")
+	}
+
+	tr, ok := cl.Transport.(*http.Transport)
+	if !ok || tr.TLSClientConfig == nil || tr.TLSClientConfig.ServerName != "example.com" {
+		t.Errorf("got transport %#v, expected one with the attached tls.Config", cl.Transport)
+	}
+}
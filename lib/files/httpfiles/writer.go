@@ -25,13 +25,12 @@ func (w *writer) Header() (http.Header, error) {
 func (h *handler) Create(ctx context.Context, uri *url.URL) (files.Writer, error) {
 	uri = elideDefaultPort(uri)
 
-	cl, ok := getClient(ctx)
-	if !ok {
-		cl = http.DefaultClient
-	}
+	cl := getClient(ctx)
 
+	// The request strips any credentials from the URL, so use its URL for names from here on.
 	req := newHTTPRequest(http.MethodPost, uri)
 	req = req.WithContext(ctx)
+	uri = req.URL
 
 	if ua, ok := getUserAgent(ctx); ok {
 		req.Header.Set("User-Agent", ua)
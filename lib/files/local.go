@@ -39,3 +39,8 @@ func (h *localFS) Create(ctx context.Context, uri *url.URL) (Writer, error) {
 func (h *localFS) List(ctx context.Context, uri *url.URL) ([]os.FileInfo, error) {
 	return ioutil.ReadDir(filename(uri))
 }
+
+// MkdirAll creates a local filesystem directory specified in the uri.Path, along with any missing parents.
+func (h *localFS) MkdirAll(ctx context.Context, uri *url.URL, perm os.FileMode) error {
+	return os.MkdirAll(filename(uri), perm)
+}
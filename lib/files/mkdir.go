@@ -0,0 +1,49 @@
+package files
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// Mkdirer is implemented by a FileStore that can create directories.
+//
+// A FileStore without real directories, such as one that uses prefixes of keys, may implement MkdirAll as a no-op.
+type Mkdirer interface {
+	MkdirAll(ctx context.Context, uri *url.URL, perm os.FileMode) error
+}
+
+// MkdirAll creates the directory at the given URL, along with any missing parents, like os.MkdirAll.
+// If the directory already exists, then MkdirAll does nothing, and returns nil.
+//
+// If the FileStore of the URL does not implement files.Mkdirer, then an error wrapping ErrNotSupported is returned.
+func MkdirAll(ctx context.Context, url string, perm os.FileMode) error {
+	return mkdirAll(ctx, url, perm)
+}
+
+func mkdirAll(ctx context.Context, resource string, perm os.FileMode) error {
+	switch resource {
+	case "", "-", "/dev/stdout", "/dev/stderr":
+		return PathError("mkdir", resource, os.ErrInvalid)
+	}
+
+	if filepath.IsAbs(resource) {
+		return os.MkdirAll(resource, perm)
+	}
+
+	if uri, err := url.Parse(resource); err == nil {
+		uri = resolveFilename(ctx, uri)
+
+		if fs, ok := getFS(uri); ok {
+			m, ok := fs.(Mkdirer)
+			if !ok {
+				return PathError("mkdir", uri.String(), ErrNotSupported)
+			}
+
+			return m.MkdirAll(ctx, uri, perm)
+		}
+	}
+
+	return os.MkdirAll(resource, perm)
+}
@@ -0,0 +1,288 @@
+package files
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// MultiError is the aggregated error of a files.CreateMulti writer,
+// with an error for each destination that failed, identified by its name.
+type MultiError []error
+
+func (e MultiError) Error() string {
+	var b strings.Builder
+
+	for i, err := range e {
+		if i > 0 {
+			b.WriteString("; ")
+		}
+
+		b.WriteString(err.Error())
+	}
+
+	return b.String()
+}
+
+// Unwrap returns the errors of each destination, so that errors.Is and errors.As will search through all of them.
+func (e MultiError) Unwrap() []error {
+	return e
+}
+
+type multiDest struct {
+	w   Writer
+	err error
+}
+
+// multiWriter writes to multiple destinations concurrently.
+type multiWriter struct {
+	mu sync.Mutex
+
+	dests      []*multiDest
+	bestEffort bool
+	err        error
+	closed     bool
+}
+
+// CreateMulti returns a files.Writer that writes to each of the resources at the given URLs concurrently.
+// Sync and Close are propagated to every destination.
+//
+// By default, the writer fails fast: the first failure of any destination is returned,
+// and every further Write or Sync returns it as well.
+// Use WithBestEffort to instead drop failed destinations, and continue to write to the remaining ones.
+//
+// The Name and Stat of the returned files.Writer are those of the first destination.
+//
+// The options are applied to the returned files.Writer, as files.Create does, e.g. WithBestEffort.
+//
+// If any destination cannot be created, then those already created are closed, and the error is returned.
+func CreateMulti(ctx context.Context, urls []string, options ...Option) (Writer, error) {
+	if len(urls) < 1 {
+		return nil, PathError("create", "", errors.New("no destinations passed to files.CreateMulti"))
+	}
+
+	var ws []Writer
+
+	for _, url := range urls {
+		w, err := Create(ctx, url)
+		if err != nil {
+			for _, w := range ws {
+				w.Close()
+			}
+
+			return nil, err
+		}
+
+		ws = append(ws, w)
+	}
+
+	m := newMultiWriter(ws...)
+
+	for _, opt := range options {
+		_, _ = opt(m)
+	}
+
+	return m, nil
+}
+
+func newMultiWriter(ws ...Writer) *multiWriter {
+	m := new(multiWriter)
+
+	for _, w := range ws {
+		m.dests = append(m.dests, &multiDest{
+			w: w,
+		})
+	}
+
+	return m
+}
+
+// WithBestEffort returns an Option that sets whether a files.CreateMulti writer drops failed destinations,
+// and continues writing to the remaining ones, rather than failing fast.
+//
+// A best-effort writer only returns an error from Write or Sync once every destination has failed,
+// while Close returns the aggregated errors of all failed destinations.
+func WithBestEffort(state bool) Option {
+	type bestEffortSetter interface {
+		SetBestEffort(bool) bool
+	}
+
+	return func(f File) (Option, error) {
+		w, ok := f.(bestEffortSetter)
+		if !ok {
+			return nil, ErrNotSupported
+		}
+
+		save := w.SetBestEffort(state)
+		return WithBestEffort(save), nil
+	}
+}
+
+// SetBestEffort sets whether failed destinations are dropped, rather than failing fast, and returns the previous value.
+func (m *multiWriter) SetBestEffort(state bool) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	prev := m.bestEffort
+	m.bestEffort = state
+	return prev
+}
+
+func (m *multiWriter) Name() string {
+	return m.dests[0].w.Name()
+}
+
+func (m *multiWriter) Stat() (os.FileInfo, error) {
+	return m.dests[0].w.Stat()
+}
+
+// failures returns the errors of every failed destination, or nil if none have failed.
+func (m *multiWriter) failures() error {
+	var errs MultiError
+
+	for _, d := range m.dests {
+		if d.err != nil {
+			errs = append(errs, d.err)
+		}
+	}
+
+	if len(errs) < 1 {
+		return nil
+	}
+
+	return errs
+}
+
+// each calls fn concurrently for every destination that has not failed, and records the errors returned.
+// It returns the aggregated errors of the destinations that failed in this call.
+func (m *multiWriter) each(op string, fn func(w Writer) error) error {
+	var wg sync.WaitGroup
+
+	errs := make([]error, len(m.dests))
+
+	for i, d := range m.dests {
+		if d.err != nil {
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, w Writer) {
+			defer wg.Done()
+
+			if err := fn(w); err != nil {
+				errs[i] = PathError(op, w.Name(), err)
+			}
+		}(i, d.w)
+	}
+
+	wg.Wait()
+
+	var failed MultiError
+
+	for i, err := range errs {
+		if err != nil {
+			m.dests[i].err = err
+			failed = append(failed, err)
+		}
+	}
+
+	if len(failed) < 1 {
+		return nil
+	}
+
+	return failed
+}
+
+// alive returns true, if any destination has not failed.
+func (m *multiWriter) alive() bool {
+	for _, d := range m.dests {
+		if d.err == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// do applies fn to the destinations according to the failure policy.
+func (m *multiWriter) do(op string, fn func(w Writer) error) error {
+	if m.closed {
+		return PathError(op, m.Name(), os.ErrClosed)
+	}
+
+	if m.err != nil {
+		return m.err
+	}
+
+	err := m.each(op, fn)
+	if err == nil {
+		return nil
+	}
+
+	if !m.bestEffort || !m.alive() {
+		m.err = m.failures()
+		return m.err
+	}
+
+	return nil
+}
+
+func (m *multiWriter) Write(b []byte) (n int, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	err = m.do("write", func(w Writer) error {
+		n, err := w.Write(b)
+		if err == nil && n < len(b) {
+			err = io.ErrShortWrite
+		}
+
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(b), nil
+}
+
+func (m *multiWriter) Sync() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.do("sync", func(w Writer) error {
+		return w.Sync()
+	})
+}
+
+// Close closes every destination, including those that have failed,
+// and returns the aggregated errors of all destinations that failed, or failed to close.
+func (m *multiWriter) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return m.failures()
+	}
+	m.closed = true
+
+	var wg sync.WaitGroup
+
+	for _, d := range m.dests {
+		wg.Add(1)
+		go func(d *multiDest) {
+			defer wg.Done()
+
+			if err := d.w.Close(); err != nil && d.err == nil {
+				d.err = PathError("close", d.w.Name(), err)
+			}
+		}(d)
+	}
+
+	wg.Wait()
+
+	return m.failures()
+}
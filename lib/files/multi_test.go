@@ -0,0 +1,215 @@
+package files
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+var errTestFailed = errors.New("test failure")
+
+// testWriter is an in-memory files.Writer, that fails after a number of writes.
+type testWriter struct {
+	bytes.Buffer
+
+	name      string
+	failAfter int
+	writes    int
+
+	syncs  int
+	closed bool
+}
+
+func (w *testWriter) Name() string               { return w.name }
+func (w *testWriter) Stat() (os.FileInfo, error) { return nil, ErrNotSupported }
+
+func (w *testWriter) Write(b []byte) (int, error) {
+	w.writes++
+	if w.failAfter > 0 && w.writes > w.failAfter {
+		return 0, errTestFailed
+	}
+
+	return w.Buffer.Write(b)
+}
+
+func (w *testWriter) Sync() error {
+	w.syncs++
+	return nil
+}
+
+func (w *testWriter) Close() error {
+	w.closed = true
+	return nil
+}
+
+func TestCreateMulti(t *testing.T) {
+	dir, err := ioutil.TempDir("", "files")
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	defer os.RemoveAll(dir)
+
+	names := []string{dir + "/a", dir + "/b", dir + "/c"}
+
+	w, err := CreateMulti(context.Background(), names)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if w.Name() != names[0] {
+		t.Errorf("got name %q, expected %q", w.Name(), names[0])
+	}
+
+	if _, err := w.Write([]byte("hello world")); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if err := w.Sync(); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	for _, name := range names {
+		b, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+
+		if string(b) != "hello world" {
+			t.Errorf("got %q in %s, expected %q", b, name, "hello world")
+		}
+	}
+
+	if _, err := w.Write([]byte("again")); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("got %v after Close, expected os.ErrClosed", err)
+	}
+
+	if _, err := CreateMulti(context.Background(), []string{dir + "/d", dir + "/nonexistent/e"}); err == nil {
+		t.Error("expected an error for a destination that cannot be created, got none")
+	}
+}
+
+func TestCreateMultiOptions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "files")
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	defer os.RemoveAll(dir)
+
+	w, err := CreateMulti(context.Background(), []string{dir + "/a", dir + "/b"}, WithBestEffort(true))
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	defer w.Close()
+
+	m, ok := w.(*multiWriter)
+	if !ok {
+		t.Fatalf("got %T, expected *multiWriter", w)
+	}
+
+	m.mu.Lock()
+	bestEffort := m.bestEffort
+	m.mu.Unlock()
+
+	if !bestEffort {
+		t.Error("expected the option to be applied to the writer")
+	}
+}
+
+func TestMultiWriterFailFast(t *testing.T) {
+	a := &testWriter{name: "a"}
+	b := &testWriter{name: "b", failAfter: 1}
+
+	w := newMultiWriter(a, b)
+
+	if _, err := w.Write([]byte("one")); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if _, err := w.Write([]byte("two")); !errors.Is(err, errTestFailed) {
+		t.Fatalf("got %v, expected %v", err, errTestFailed)
+	}
+
+	if _, err := w.Write([]byte("three")); !errors.Is(err, errTestFailed) {
+		t.Errorf("got %v, expected the error to be sticky", err)
+	}
+
+	if got := a.String(); got != "onetwo" {
+		t.Errorf("got %q, expected %q", got, "onetwo")
+	}
+
+	err := w.Close()
+
+	var merr MultiError
+	if !errors.As(err, &merr) || len(merr) != 1 {
+		t.Fatalf("got %#v, expected a MultiError with one error", err)
+	}
+
+	var perr *os.PathError
+	if !errors.As(merr[0], &perr) || perr.Path != "b" {
+		t.Errorf("got %v, expected an error for destination b", merr[0])
+	}
+
+	if !a.closed || !b.closed {
+		t.Error("expected every destination to be closed")
+	}
+}
+
+func TestMultiWriterBestEffort(t *testing.T) {
+	a := &testWriter{name: "a"}
+	b := &testWriter{name: "b", failAfter: 1}
+	c := &testWriter{name: "c", failAfter: 2}
+
+	w := newMultiWriter(a, b, c)
+
+	if _, err := WithBestEffort(true)(w); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	for _, s := range []string{"one", "two", "three"} {
+		if _, err := w.Write([]byte(s)); err != nil {
+			t.Fatal("unexpected error", err)
+		}
+	}
+
+	if err := w.Sync(); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if got := a.String(); got != "onetwothree" {
+		t.Errorf("got %q, expected %q", got, "onetwothree")
+	}
+
+	if a.syncs != 1 || b.syncs != 0 || c.syncs != 0 {
+		t.Errorf("got syncs %d, %d, %d, expected only the remaining destination to be synced", a.syncs, b.syncs, c.syncs)
+	}
+
+	var merr MultiError
+	if err := w.Close(); !errors.As(err, &merr) || len(merr) != 2 {
+		t.Fatalf("got %#v, expected a MultiError with two errors", err)
+	}
+
+	if !a.closed || !b.closed || !c.closed {
+		t.Error("expected every destination to be closed")
+	}
+
+	// Once every destination has failed, a best-effort writer fails too.
+	d := &testWriter{name: "d", failAfter: 1}
+
+	w = newMultiWriter(d)
+	w.SetBestEffort(true)
+
+	if _, err := w.Write([]byte("one")); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if _, err := w.Write([]byte("two")); !errors.Is(err, errTestFailed) {
+		t.Errorf("got %v, expected %v", err, errTestFailed)
+	}
+}
@@ -11,14 +11,34 @@ import (
 // Open returns a files.Reader, which can be used to read content from the resource at the given URL.
 //
 // All errors and reversion functions returned by Option arguments are discarded.
+//
+// If the files.Reader does not verify checksums itself, then WithReadChecksum is applied to a wrapper around it, and the wrapper is returned.
+// The wrapper forwards Stat, Seek, ReadAt, Checksum and Header to the files.Reader.
 func Open(ctx context.Context, url string, options ...Option) (Reader, error) {
 	f, err := open(ctx, url)
 	if err != nil {
 		return nil, err
 	}
 
+	var cr *checksumReader
+
 	for _, opt := range options {
-		_, _ = opt(f)
+		_, err := opt(f)
+		if err != errNoChecksumSetter {
+			continue
+		}
+
+		if cr == nil {
+			cr = &checksumReader{
+				Reader: f,
+			}
+		}
+
+		_, _ = opt(cr)
+	}
+
+	if cr != nil && cr.c != nil {
+		return newChecksumReader(cr), nil
 	}
 
 	return f, nil
@@ -54,6 +54,14 @@ type copyConfig struct {
 	bwInterval time.Duration
 	bwRunning  observer
 	bwLifetime observer
+
+	maxBandwidth int64
+	progress     func(written, total int64, rate float64)
+
+	parallelism int
+	chunkSize   int
+
+	checksum *checksum
 }
 
 // CopyOption defines a function that applies a value or setting for a specific files.Copy operation.
@@ -128,3 +136,30 @@ func WithIntervalBandwidthMetrics(running interface{ Observe(float64) }, n int,
 		return WithIntervalBandwidthMetrics(saveOb, saveCount, saveDur)
 	}
 }
+
+// WithMaxBandwidth limits the files.Copy to an average of bytesPerSec bytes per second.
+// A limit that is zero or less is unlimited.
+func WithMaxBandwidth(bytesPerSec int64) CopyOption {
+	return func(c *copyConfig) CopyOption {
+		save := c.maxBandwidth
+
+		c.maxBandwidth = bytesPerSec
+
+		return WithMaxBandwidth(save)
+	}
+}
+
+// WithProgress calls fn with the progress of the files.Copy, once every metrics interval, and once more when it is done.
+//
+// The written and total are in bytes, where total is the size of the source from its Stat(), or -1 if it is not known.
+// The rate is the average bandwidth since the start of the files.Copy in bytes per second.
+// See ETA for estimating when the files.Copy will complete.
+func WithProgress(fn func(written, total int64, rate float64)) CopyOption {
+	return func(c *copyConfig) CopyOption {
+		save := c.progress
+
+		c.progress = fn
+
+		return WithProgress(save)
+	}
+}
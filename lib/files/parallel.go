@@ -0,0 +1,263 @@
+package files
+
+import (
+	"context"
+	"errors"
+	"hash"
+	"io"
+	"sync"
+)
+
+const (
+	defaultParallelism = 4
+	defaultChunkSize   = 4 * 1024 * 1024
+)
+
+// WithParallelism sets how many ranges a files.ParallelCopy reads concurrently.
+func WithParallelism(n int) CopyOption {
+	return func(c *copyConfig) CopyOption {
+		save := c.parallelism
+
+		c.parallelism = n
+
+		return WithParallelism(save)
+	}
+}
+
+// WithChunkSize sets the size of the ranges that a files.ParallelCopy reads.
+func WithChunkSize(size int) CopyOption {
+	return func(c *copyConfig) CopyOption {
+		save := c.chunkSize
+
+		c.chunkSize = size
+
+		return WithChunkSize(save)
+	}
+}
+
+type chunk struct {
+	off int64
+	buf []byte
+
+	n   int
+	err error
+
+	done chan struct{}
+}
+
+// ParallelCopy copies from src to dst like files.Copy, but if src implements io.ReaderAt, and its size is known from its Stat(),
+// then it reads ranges of src concurrently, and writes them to dst in order.
+// If dst implements io.WriterAt, then each range is instead written directly at its offset, as soon as it has been read.
+//
+// At most the parallelism times the chunk size of data is held in memory at any one time.
+// The watchdog timeout applies to waiting for each range to complete, and metrics are reported as each range is written.
+//
+// If src cannot be read in parallel, then ParallelCopy falls back to files.Copy.
+func ParallelCopy(ctx context.Context, dst io.Writer, src io.Reader, opts ...CopyOption) (written int64, err error) {
+	if dst == nil {
+		return 0, errors.New("nil io.Writer passed to files.ParallelCopy")
+	}
+
+	ra, ok := src.(io.ReaderAt)
+	size := sizeOf(src)
+	if !ok || size <= 0 {
+		return Copy(ctx, dst, src, opts...)
+	}
+
+	c := new(copyConfig)
+
+	for _, opt := range opts {
+		// intentionally throwing away the reverting functions.
+		_ = opt(c)
+	}
+
+	if c.parallelism < 1 {
+		c.parallelism = defaultParallelism
+	}
+
+	if c.chunkSize < 1 {
+		c.chunkSize = defaultChunkSize
+	}
+
+	var hasher hash.Hash
+	if c.checksum != nil {
+		var err error
+		if c.checksum, hasher, err = newChecksum(c.checksum.hash, c.checksum.expected); err != nil {
+			return 0, err
+		}
+	}
+
+	wa, _ := dst.(io.WriterAt)
+
+	limiter := c.newLimiter()
+
+	// Bound the memory used, by only allocating buffers up to the parallelism, and then reusing them.
+	bufs := make(chan []byte, c.parallelism)
+	for i := 0; i < c.parallelism; i++ {
+		bufs <- nil
+	}
+
+	// Prevent a write outside of returning from this function.
+	//
+	// Every WriteAt in progress is waited for before returning, and once stopped is set, no further WriteAt is started.
+	// On an error, the ranges still being read are not waited for, as a stalled ReadAt would stall the return as well.
+	// They drain in the background instead, without writing anything, and their buffers are simply dropped.
+	var (
+		mu      sync.Mutex
+		stopped bool
+		writes  sync.WaitGroup
+	)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer func() {
+		cancel()
+
+		mu.Lock()
+		stopped = true
+		mu.Unlock()
+
+		writes.Wait()
+	}()
+
+	// startWrite returns false, if ParallelCopy is returning, and the range must not be written.
+	// Otherwise, the caller MUST call writes.Done once it has finished writing.
+	startWrite := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if stopped {
+			return false
+		}
+
+		writes.Add(1)
+		return true
+	}
+
+	fetch := func(ch *chunk) {
+		defer close(ch.done)
+
+		if limiter != nil {
+			if ch.err = limiter.Wait(ctx, len(ch.buf)); ch.err != nil {
+				return
+			}
+		}
+
+		ch.n, ch.err = ra.ReadAt(ch.buf, ch.off)
+		if ch.err == io.EOF && ch.n == len(ch.buf) {
+			ch.err = nil
+		}
+
+		if ch.err != nil {
+			if ch.err == io.EOF {
+				ch.err = io.ErrUnexpectedEOF
+			}
+			return
+		}
+
+		if wa != nil {
+			if !startWrite() {
+				ch.err = context.Canceled
+				return
+			}
+			defer writes.Done()
+
+			ch.n, ch.err = wa.WriteAt(ch.buf, ch.off)
+		}
+	}
+
+	// The chunks are queued in order, so that they can be written in order.
+	queue := make(chan *chunk, c.parallelism)
+
+	go func() {
+		defer close(queue)
+
+		for off := int64(0); off < size; off += int64(c.chunkSize) {
+			var buf []byte
+
+			select {
+			case buf = <-bufs:
+			case <-ctx.Done():
+				return
+			}
+
+			l := int64(c.chunkSize)
+			if size-off < l {
+				l = size - off
+			}
+
+			if int64(cap(buf)) < l {
+				buf = make([]byte, l)
+			}
+
+			ch := &chunk{
+				off:  off,
+				buf:  buf[:l],
+				done: make(chan struct{}),
+			}
+
+			go fetch(ch)
+
+			select {
+			case queue <- ch:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	w := &deadlineWriter{
+		ctx: ctx,
+		w:   dst,
+	}
+
+	t := c.newWatchdog()
+
+	m := newCopyMeter(c, src)
+
+	for ch := range queue {
+		c.resetWatchdog(t)
+
+		select {
+		case <-ch.done:
+
+		case <-t.C:
+			return m.written, ErrWatchdogExpired
+
+		case <-ctx.Done():
+			return m.written, ctx.Err()
+		}
+
+		if ch.err != nil {
+			return m.written, ch.err
+		}
+
+		if wa == nil {
+			n, err := w.Write(ch.buf)
+			if err != nil {
+				m.written += int64(n)
+				return m.written, err
+			}
+		}
+
+		if hasher != nil {
+			// The ranges are completed in order here, so the checksum is computed in order.
+			hasher.Write(ch.buf)
+		}
+
+		m.add(int64(ch.n))
+
+		bufs <- ch.buf
+	}
+
+	if err := ctx.Err(); err != nil {
+		return m.written, err
+	}
+
+	m.done()
+
+	if hasher != nil {
+		return m.written, c.checksum.verify(src, hasher.Sum(nil))
+	}
+
+	return m.written, nil
+}
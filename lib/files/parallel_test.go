@@ -0,0 +1,243 @@
+package files
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingReaderAt counts concurrent reads, fails reads at the given offset,
+// and stalls reads at the given offset until stall is closed.
+type countingReaderAt struct {
+	*os.File
+
+	active, max int32
+	failAt      int64
+
+	stallAt int64
+	stall   chan struct{}
+}
+
+func (r *countingReaderAt) ReadAt(b []byte, off int64) (int, error) {
+	n := atomic.AddInt32(&r.active, 1)
+	defer atomic.AddInt32(&r.active, -1)
+
+	for {
+		max := atomic.LoadInt32(&r.max)
+		if n <= max || atomic.CompareAndSwapInt32(&r.max, max, n) {
+			break
+		}
+	}
+
+	if r.failAt > 0 && off == r.failAt {
+		return 0, errors.New("read failed")
+	}
+
+	if r.stall != nil && off == r.stallAt {
+		<-r.stall
+	}
+
+	return r.File.ReadAt(b, off)
+}
+
+func TestParallelCopy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "files")
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	defer os.RemoveAll(dir)
+
+	data := make([]byte, 10500)
+	rand.Read(data)
+
+	src, err := os.Create(dir + "/src")
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	defer src.Close()
+
+	if _, err := src.Write(data); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	ctx := context.Background()
+
+	r := &countingReaderAt{File: src}
+
+	var buf bytes.Buffer
+
+	n, err := ParallelCopy(ctx, &buf, r, WithParallelism(3), WithChunkSize(1000))
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if n != int64(len(data)) || !bytes.Equal(buf.Bytes(), data) {
+		t.Errorf("got %d bytes, expected the %d bytes of the source in order", n, len(data))
+	}
+
+	if r.max > 3 {
+		t.Errorf("got %d concurrent reads, expected no more than 3", r.max)
+	}
+
+	// A destination that implements io.WriterAt.
+	dst, err := os.Create(dir + "/dst")
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	defer dst.Close()
+
+	if _, err := ParallelCopy(ctx, dst, r, WithChunkSize(1000)); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	b, err := ioutil.ReadFile(dir + "/dst")
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if !bytes.Equal(b, data) {
+		t.Errorf("got %d bytes written at offsets, expected the %d bytes of the source", len(b), len(data))
+	}
+
+	// A failing range.
+	r.failAt = 5000
+	buf.Reset()
+
+	n, err = ParallelCopy(ctx, &buf, r, WithParallelism(2), WithChunkSize(1000))
+	if err == nil {
+		t.Fatal("expected an error, got none")
+	}
+
+	if n != 5000 {
+		t.Errorf("got %d bytes, expected the %d bytes before the failed range", n, 5000)
+	}
+}
+
+func TestParallelCopyStalled(t *testing.T) {
+	dir, err := ioutil.TempDir("", "files")
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	defer os.RemoveAll(dir)
+
+	src, err := os.Create(dir + "/src")
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	defer src.Close()
+
+	if _, err := src.Write(make([]byte, 4000)); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	ra := &countingReaderAt{
+		File:    src,
+		stallAt: 1000,
+		stall:   make(chan struct{}),
+	}
+	defer close(ra.stall)
+
+	var buf bytes.Buffer
+
+	start := time.Now()
+
+	_, err = ParallelCopy(context.Background(), &buf, ra, WithChunkSize(1000), WithWatchdogTimeout(50*time.Millisecond))
+	if err != ErrWatchdogExpired {
+		t.Fatalf("got %v, expected %v", err, ErrWatchdogExpired)
+	}
+
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("returning took %v, expected not to wait for the stalled read", d)
+	}
+}
+
+// slowWriterAt is an io.WriterAt that takes longer to write each later range, and records any write after it is marked done.
+type slowWriterAt struct {
+	active int32
+	done   int32
+	late   int32
+}
+
+func (w *slowWriterAt) Write(b []byte) (int, error) {
+	return w.WriteAt(b, 0)
+}
+
+func (w *slowWriterAt) WriteAt(b []byte, off int64) (int, error) {
+	atomic.AddInt32(&w.active, 1)
+	defer atomic.AddInt32(&w.active, -1)
+
+	if atomic.LoadInt32(&w.done) != 0 {
+		atomic.AddInt32(&w.late, 1)
+	}
+
+	time.Sleep(time.Duration(1+off/1000) * 50 * time.Millisecond)
+
+	return len(b), nil
+}
+
+func TestParallelCopyNoLateWrites(t *testing.T) {
+	dir, err := ioutil.TempDir("", "files")
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	defer os.RemoveAll(dir)
+
+	src, err := os.Create(dir + "/src")
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	defer src.Close()
+
+	if _, err := src.Write(make([]byte, 4000)); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	ra := &countingReaderAt{
+		File:    src,
+		failAt:  1000,
+		stallAt: 3000,
+		stall:   make(chan struct{}),
+	}
+
+	w := new(slowWriterAt)
+
+	if _, err := ParallelCopy(context.Background(), w, ra, WithChunkSize(1000)); err == nil {
+		t.Fatal("expected an error, got none")
+	}
+
+	atomic.StoreInt32(&w.done, 1)
+
+	if n := atomic.LoadInt32(&w.active); n != 0 {
+		t.Errorf("got %d writes still in progress after returning, expected none", n)
+	}
+
+	// Let the stalled range finish reading, which must not then be written.
+	close(ra.stall)
+	time.Sleep(100 * time.Millisecond)
+
+	if n := atomic.LoadInt32(&w.late); n != 0 {
+		t.Errorf("got %d writes after returning, expected none", n)
+	}
+}
+
+func TestParallelCopyFallback(t *testing.T) {
+	data := []byte("hello world")
+
+	var buf bytes.Buffer
+
+	// A bytes.Reader is an io.ReaderAt, but it has no Stat, so its size is not known.
+	n, err := ParallelCopy(context.Background(), &buf, bytes.NewReader(data))
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if n != int64(len(data)) || buf.String() != string(data) {
+		t.Errorf("got %q, expected %q", buf.String(), data)
+	}
+}
@@ -7,7 +7,10 @@ import (
 	_ "github.com/puellanivis/breton/lib/files/cachefiles"
 	_ "github.com/puellanivis/breton/lib/files/clipboard"
 	_ "github.com/puellanivis/breton/lib/files/datafiles"
+	_ "github.com/puellanivis/breton/lib/files/encfiles"
+	_ "github.com/puellanivis/breton/lib/files/envfiles"
 	_ "github.com/puellanivis/breton/lib/files/home"
 	_ "github.com/puellanivis/breton/lib/files/httpfiles"
 	_ "github.com/puellanivis/breton/lib/files/socketfiles"
+	_ "github.com/puellanivis/breton/lib/files/xdg"
 )
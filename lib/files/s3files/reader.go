@@ -2,7 +2,16 @@ package s3files
 
 import (
 	"context"
+	"crypto"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/puellanivis/breton/lib/files"
@@ -12,6 +21,126 @@ import (
 	"github.com/aws/aws-sdk-go/service/s3"
 )
 
+type reader struct {
+	*wrapper.Reader
+
+	ctx  context.Context
+	cl   *s3.S3
+	size int64
+	req  *s3.GetObjectInput
+
+	checksums map[crypto.Hash][]byte
+}
+
+// Checksum implements files.Checksummer.
+//
+// An MD5 digest is available from the ETag, unless the object was uploaded in multiple parts, or is encrypted with KMS.
+// SHA-1 and SHA-256 digests are available, if the object was uploaded with that checksum as a whole.
+func (r *reader) Checksum(h crypto.Hash) ([]byte, error) {
+	sum, ok := r.checksums[h]
+	if !ok {
+		return nil, files.PathError("checksum", r.Name(), files.ErrNotSupported)
+	}
+
+	return sum, nil
+}
+
+// getChecksums returns the digests of the object that can be taken from the response.
+func getChecksums(res *s3.HeadObjectOutput) map[crypto.Hash][]byte {
+	sums := make(map[crypto.Hash][]byte)
+
+	// The ETag of an object is only the MD5 of its content, if it was uploaded in one part, and not encrypted with KMS.
+	if etag := strings.Trim(aws.StringValue(res.ETag), `"`); len(etag) == 2*md5.Size {
+		if !strings.HasPrefix(aws.StringValue(res.ServerSideEncryption), s3.ServerSideEncryptionAwsKms) {
+			if sum, err := hex.DecodeString(etag); err == nil {
+				sums[crypto.MD5] = sum
+			}
+		}
+	}
+
+	// A checksum of a multipart upload is a checksum of checksums, and ends in a part count, which fails to decode.
+	if sum, err := base64.StdEncoding.DecodeString(aws.StringValue(res.ChecksumSHA1)); err == nil && len(sum) == sha1.Size {
+		sums[crypto.SHA1] = sum
+	}
+
+	if sum, err := base64.StdEncoding.DecodeString(aws.StringValue(res.ChecksumSHA256)); err == nil && len(sum) == sha256.Size {
+		sums[crypto.SHA256] = sum
+	}
+
+	return sums
+}
+
+// ReadAt reads len(b) bytes of the object starting at offset off, with a ranged GetObject of its own.
+// It does not affect the offset of Read, and may be called concurrently.
+func (r *reader) ReadAt(b []byte, off int64) (n int, err error) {
+	if off >= r.size {
+		return 0, io.EOF
+	}
+
+	if len(b) < 1 {
+		return 0, nil
+	}
+
+	end := off + int64(len(b))
+	if end > r.size {
+		end = r.size
+	}
+
+	req := *r.req
+	req.Range = aws.String(fmt.Sprintf("bytes=%d-%d", off, end-1))
+
+	res, err := r.cl.GetObjectWithContext(r.ctx, &req)
+	if err != nil {
+		return 0, files.PathError("read", r.Name(), normalizeError(err))
+	}
+	defer res.Body.Close()
+
+	n, err = io.ReadFull(res.Body, b[:end-off])
+	if err != nil {
+		return n, files.PathError("read", r.Name(), err)
+	}
+
+	if n < len(b) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+// body reads the whole object, but only issues its GetObject on the first Read,
+// so that opening an object only to Stat it, or to read it with ReadAt, does not hold a download open.
+//
+// It is only ever used through a wrapper.Reader, which serializes Read and Close.
+type body struct {
+	ctx  context.Context
+	cl   *s3.S3
+	name string
+	req  *s3.GetObjectInput
+
+	rc io.ReadCloser
+}
+
+func (b *body) Read(p []byte) (int, error) {
+	if b.rc == nil {
+		res, err := b.cl.GetObjectWithContext(b.ctx, b.req)
+		if err != nil {
+			return 0, files.PathError("read", b.name, normalizeError(err))
+		}
+
+		b.rc = res.Body
+	}
+
+	return b.rc.Read(p)
+}
+
+func (b *body) Close() error {
+	if b.rc == nil {
+		return nil
+	}
+
+	return b.rc.Close()
+}
+
 func (h *handler) Open(ctx context.Context, uri *url.URL) (files.Reader, error) {
 	bucket, key, err := getBucketKey("open", uri)
 	if err != nil {
@@ -23,14 +152,14 @@ func (h *handler) Open(ctx context.Context, uri *url.URL) (files.Reader, error)
 		return nil, files.PathError("open", uri.String(), err)
 	}
 
-	req := &s3.GetObjectInput{
+	res, err := cl.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
 		Bucket: aws.String(bucket),
 		Key:    aws.String(key),
-	}
 
-	res, err := cl.GetObjectWithContext(ctx, req)
+		ChecksumMode: aws.String(s3.ChecksumModeEnabled),
+	})
 	if err != nil {
-		return nil, files.PathError("read", uri.String(), normalizeError(err))
+		return nil, files.PathError("open", uri.String(), normalizeError(err))
 	}
 
 	var l int64
@@ -43,5 +172,30 @@ func (h *handler) Open(ctx context.Context, uri *url.URL) (files.Reader, error)
 		lm = *res.LastModified
 	}
 
-	return wrapper.NewReaderWithInfo(res.Body, wrapper.NewInfo(uri, int(l), lm)), nil
+	// Ensure that everything read later is from the same version of the object.
+	req := &s3.GetObjectInput{
+		Bucket:  aws.String(bucket),
+		Key:     aws.String(key),
+		IfMatch: res.ETag,
+	}
+
+	info := wrapper.NewInfo(uri, int(l), lm)
+
+	b := &body{
+		ctx:  ctx,
+		cl:   cl,
+		name: info.Name(),
+		req:  req,
+	}
+
+	return &reader{
+		Reader: wrapper.NewReaderWithInfo(b, info),
+
+		ctx:  ctx,
+		cl:   cl,
+		size: l,
+		req:  req,
+
+		checksums: getChecksums(res),
+	}, nil
 }
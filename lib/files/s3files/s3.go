@@ -113,6 +113,15 @@ func getBucketKey(op string, uri *url.URL) (bucket, key string, err error) {
 	return uri.Host, uri.Path, nil
 }
 
+// MkdirAll does nothing, as S3 has no directories, only keys that share a prefix.
+func (h *handler) MkdirAll(ctx context.Context, uri *url.URL, perm os.FileMode) error {
+	if uri.Host == "" {
+		return files.PathError("mkdir", uri.String(), os.ErrInvalid)
+	}
+
+	return nil
+}
+
 func (h *handler) List(ctx context.Context, uri *url.URL) ([]os.FileInfo, error) {
 	if uri.Host == "" {
 		return nil, files.PathError("list", uri.String(), os.ErrInvalid)
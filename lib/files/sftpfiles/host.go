@@ -1,9 +1,13 @@
 package sftpfiles
 
 import (
-	"errors"
+	"fmt"
+	"net"
 	"net/url"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/puellanivis/breton/lib/os/user"
 
@@ -17,14 +21,38 @@ type Host struct {
 	mu   sync.Mutex
 	conn *ssh.Client
 	cl   *sftp.Client
+	dead chan struct{}
 
-	uri *url.URL
+	// releaseJump marks the current connection as no longer using the jump host.
+	releaseJump func()
 
-	auths []ssh.AuthMethod
+	active int
+	idle   *time.Timer
+	sem    chan struct{}
+
+	metrics *metricsPack
+
+	uri  *url.URL
+	jump *Host
+
+	auths        []ssh.AuthMethod
+	signers      []func() ([]ssh.Signer, error)
+	identities   []string
+	certificates []string
+	passphrase   PassphraseFunc
+	keys         map[string]ssh.Signer
 
 	ignoreHostkey bool
 	hostkey       ssh.HostKeyCallback
 	hostkeyAlgos  []string
+	hostkeyPolicy HostKeyPolicy
+	knownHosts    []string
+	fingerprints  []string
+
+	keepaliveInterval time.Duration
+	keepaliveCount    int
+	idleTimeout       time.Duration
+	maxRequests       int
 }
 
 var (
@@ -47,6 +75,9 @@ func getUser() *url.Userinfo {
 
 // NewHost returns a Host defined for a specific host/user based on a given URL.
 // No connection is made, and no authentication or hostkey validation is defined.
+//
+// The Host sends keepalive requests, closes its connection when idle, and limits concurrent requests,
+// according to DefaultKeepaliveInterval, DefaultKeepaliveCount, DefaultIdleTimeout, and DefaultMaxRequests.
 func NewHost(uri *url.URL) *Host {
 	var auths []ssh.AuthMethod
 
@@ -69,10 +100,20 @@ func NewHost(uri *url.URL) *Host {
 		uri.Host += ":22"
 	}
 
-	return &Host{
+	h := &Host{
 		uri:   uri,
 		auths: auths,
+
+		metrics: baseMetrics.WithLabels(hostLabel.WithValue(uri.String())),
+
+		keepaliveInterval: DefaultKeepaliveInterval,
+		keepaliveCount:    DefaultKeepaliveCount,
+		idleTimeout:       DefaultIdleTimeout,
 	}
+
+	_ = h.SetMaxRequests(DefaultMaxRequests)
+
+	return h
 }
 
 // Name returns an identifying name of the Host composed of the authority section of the URL: //user[:pass]@hostname:port
@@ -80,17 +121,32 @@ func (h *Host) Name() string {
 	return h.uri.String()
 }
 
-func (h *Host) close() error {
-	if h.cl == nil {
+// close closes and invalidates the Host's current connection, recording the reason it was closed.
+//
+// Caller MUST hold the lock.
+func (h *Host) close(reason string) error {
+	if h.conn == nil {
 		return nil
 	}
 
-	err := h.cl.Close()
+	var err error
+	if h.cl != nil {
+		err = h.cl.Close()
+	}
+
 	if err2 := h.conn.Close(); err == nil {
 		err = err2
 	}
 
-	h.cl, h.conn = nil, nil
+	h.cl, h.conn, h.dead = nil, nil, nil
+
+	if h.releaseJump != nil {
+		h.releaseJump()
+		h.releaseJump = nil
+	}
+
+	h.metrics.connected.Set(0)
+	h.metrics.disconnected(reason)
 
 	return err
 }
@@ -100,19 +156,30 @@ func (h *Host) Close() error {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	return h.close()
+	return h.close(reasonClosed)
 }
 
-func (h *Host) client() *sftp.Client {
-	if h.cl == nil {
-		return nil
+// alive returns true if the Host has a current connection that has not ended.
+//
+// Caller MUST hold the lock.
+func (h *Host) alive() bool {
+	if h.conn == nil {
+		return false
+	}
+
+	select {
+	case <-h.dead:
+		// The connection has ended, so invalidate it.
+		_ = h.close(reasonDead)
+		return false
+	default:
 	}
 
-	if _, err := h.cl.Getwd(); err != nil {
-		// We cannot get the current working directory,
-		// So, invalidate our connections, and return nil.
-		_ = h.close()
+	return true
+}
 
+func (h *Host) client() *sftp.Client {
+	if !h.alive() {
 		return nil
 	}
 
@@ -137,34 +204,143 @@ func (h *Host) Connect() (*sftp.Client, error) {
 		return cl, nil
 	}
 
-	hk := h.hostkey
-	if h.ignoreHostkey {
-		hk = ssh.InsecureIgnoreHostKey()
+	conn, err := h.sshClient()
+	if err != nil {
+		return nil, err
 	}
 
-	if hk == nil {
-		return nil, errors.New("no hostkey validation defined")
+	cl, err := sftp.NewClient(conn)
+	if err != nil {
+		_ = h.close(reasonClosed)
+		return nil, err
 	}
 
-	conn, err := ssh.Dial("tcp", h.uri.Host, &ssh.ClientConfig{
-		User:              h.uri.User.Username(),
-		Auth:              h.cloneAuths(),
-		HostKeyCallback:   hk,
-		HostKeyAlgorithms: h.hostkeyAlgos,
-	})
+	h.cl = cl
+
+	return cl, nil
+}
+
+// sshClient either returns the currently connected ssh.Client, or makes a new connection based on Host.
+// If the Host has a jump host, then the connection is made through the jump host's connection.
+//
+// Caller MUST hold the lock.
+func (h *Host) sshClient() (*ssh.Client, error) {
+	if h.alive() {
+		return h.conn, nil
+	}
+
+	conn, err := h.dial()
 	if err != nil {
+		h.metrics.connectErrors.Inc()
 		return nil, err
 	}
 
-	cl, err := sftp.NewClient(conn)
+	h.conn = conn
+	h.dead = make(chan struct{})
+
+	go h.watch(conn, h.dead)
+
+	if h.keepaliveInterval > 0 {
+		count := h.keepaliveCount
+		if count < 1 {
+			count = 1
+		}
+
+		go h.keepalive(conn, h.dead, h.keepaliveInterval, count)
+	}
+
+	h.metrics.connects.Inc()
+	h.metrics.connected.Set(1)
+
+	return conn, nil
+}
+
+// dial makes a new ssh connection based on the Host.
+//
+// Caller MUST hold the lock.
+func (h *Host) dial() (*ssh.Client, error) {
+	hk, algos, err := h.hostKeyCallback()
 	if err != nil {
-		conn.Close()
 		return nil, err
 	}
 
-	h.conn, h.cl = conn, cl
+	auths := h.cloneAuths()
+	if pk := h.publicKeys(); pk != nil {
+		// The ssh client tries each method only once, so all the public keys must be offered by the same method.
+		auths = append([]ssh.AuthMethod{pk}, auths...)
+	}
 
-	return cl, nil
+	// The ssh package does not wrap the error from the hostkey callback, so we keep it to return it as is.
+	var hkErr error
+
+	config := &ssh.ClientConfig{
+		User: h.uri.User.Username(),
+		Auth: auths,
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			hkErr = hk(hostname, remote, key)
+			return hkErr
+		},
+		HostKeyAlgorithms: algos,
+	}
+
+	if h.jump == nil {
+		conn, err := ssh.Dial("tcp", h.uri.Host, config)
+		if err != nil && hkErr != nil {
+			return nil, hkErr
+		}
+
+		return conn, err
+	}
+
+	// The jump host is in use for as long as this connection is open.
+	release := h.jump.use()
+
+	nc, err := h.jump.dialThrough(h.uri.Host)
+	if err != nil {
+		release()
+		return nil, fmt.Errorf("jump host %s: %w", h.jump.Name(), err)
+	}
+
+	c, chans, reqs, err := ssh.NewClientConn(nc, h.uri.Host, config)
+	if err != nil {
+		nc.Close()
+		release()
+
+		if hkErr != nil {
+			return nil, hkErr
+		}
+
+		return nil, err
+	}
+
+	h.releaseJump = release
+
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// dialThrough opens a connection to the given address, forwarded through the Host's ssh connection.
+func (h *Host) dialThrough(addr string) (net.Conn, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	conn, err := h.sshClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return conn.Dial("tcp", addr)
+}
+
+// SetJumpHost sets the Host through which connections to the Host are made, and returns the previous value.
+// Each jump host uses its own authentication and hostkey validation.
+//
+// If the jump host is nil, then connections are made directly.
+func (h *Host) SetJumpHost(jump *Host) *Host {
+	save := h.jump
+
+	h.jump = jump
+
+	return save
 }
 
 func (h *Host) cloneAuths() []ssh.AuthMethod {
@@ -190,6 +366,140 @@ func (h *Host) SetAuths(auths []ssh.AuthMethod) []ssh.AuthMethod {
 	return save
 }
 
+// addSigners adds a source of signers to be offered for public key authentication.
+func (h *Host) addSigners(fn func() ([]ssh.Signer, error)) {
+	h.signers = append(h.signers, fn)
+}
+
+// SetIdentityFiles sets the private key files to be offered for public key authentication by the Host, and returns the previous value.
+//
+// If no identity files are set, then the default files ~/.ssh/id_rsa, ~/.ssh/id_ecdsa, ~/.ssh/id_ed25519, and ~/.ssh/id_dsa are used.
+func (h *Host) SetIdentityFiles(filenames []string) []string {
+	save := h.identities
+
+	h.identities = filenames
+	h.keys = nil
+
+	return save
+}
+
+// AddIdentityFile adds the given private key file to the identity files of the Host, and returns the previous value.
+func (h *Host) AddIdentityFile(filename string) []string {
+	for _, identity := range h.identities {
+		if identity == filename {
+			return h.identities
+		}
+	}
+
+	return h.SetIdentityFiles(append(h.identities[:len(h.identities):len(h.identities)], filename))
+}
+
+// SetPassphraseCallback sets the callback used to obtain the passphrase for encrypted identity files, and returns the previous value.
+func (h *Host) SetPassphraseCallback(fn PassphraseFunc) PassphraseFunc {
+	save := h.passphrase
+
+	h.passphrase = fn
+	h.keys = nil
+
+	return save
+}
+
+// publicKeys returns an ssh.AuthMethod offering the signers from all sources and identity files of the Host,
+// or nil if there are none.
+//
+// Caller MUST hold the lock.
+func (h *Host) publicKeys() ssh.AuthMethod {
+	sources := append([]func() ([]ssh.Signer, error){}, h.signers...)
+	identities := h.identitySigners()
+
+	if len(sources) < 1 && len(identities) < 1 {
+		return nil
+	}
+
+	return ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+		var signers []ssh.Signer
+
+		for _, source := range sources {
+			s, err := source()
+			if err != nil {
+				continue
+			}
+
+			signers = append(signers, s...)
+		}
+
+		return append(signers, identities...), nil
+	})
+}
+
+// applyConfig applies the settings from an OpenSSH client configuration to the Host.
+func (h *Host) applyConfig(hc *HostConfig) {
+	if hc == nil {
+		return
+	}
+
+	// Modifications of the default list (+, -, ^) cannot be expressed here, so only a full list is used.
+	if algos := hc.Get("HostKeyAlgorithms"); algos != "" && !strings.ContainsAny(algos[:1], "+-^") {
+		h.hostkeyAlgos = strings.Split(algos, ",")
+	}
+
+	if v := hc.Get("StrictHostKeyChecking"); v != "" {
+		if policy, err := ParseHostKeyPolicy(v); err == nil {
+			h.hostkeyPolicy = policy
+		}
+	}
+
+	if filenames := hc.GetAll("UserKnownHostsFile"); len(filenames) > 0 {
+		var knownHosts []string
+
+		for _, filename := range filenames {
+			if strings.EqualFold(filename, "none") {
+				continue
+			}
+
+			knownHosts = append(knownHosts, hc.expandPath(filename, h.uri))
+		}
+
+		// Keep any global files, which follow the user files.
+		if len(h.knownHosts) > 1 {
+			knownHosts = append(knownHosts, h.knownHosts[1:]...)
+		}
+
+		h.knownHosts = knownHosts
+	}
+
+	if v := hc.Get("ServerAliveInterval"); v != "" {
+		if secs, err := strconv.Atoi(v); err == nil {
+			h.keepaliveInterval = time.Duration(secs) * time.Second
+		}
+	}
+
+	if v := hc.Get("ServerAliveCountMax"); v != "" {
+		if count, err := strconv.Atoi(v); err == nil {
+			h.keepaliveCount = count
+		}
+	}
+
+	var identities []string
+	for _, filename := range hc.GetAll("IdentityFile") {
+		if strings.EqualFold(filename, "none") {
+			continue
+		}
+
+		identities = append(identities, hc.expandPath(filename, h.uri))
+	}
+
+	_ = h.SetIdentityFiles(append(h.identities, identities...))
+
+	for _, filename := range hc.GetAll("CertificateFile") {
+		if strings.EqualFold(filename, "none") {
+			continue
+		}
+
+		h.certificates = append(h.certificates, hc.expandPath(filename, h.uri))
+	}
+}
+
 // IgnoreHostKeys sets a flag that Host should ignore Host keys when connecting.
 // THIS IS INSECURE.
 func (h *Host) IgnoreHostKeys(state bool) bool {
@@ -0,0 +1,299 @@
+package sftpfiles
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// HostKeyPolicy defines how a Host treats host keys that are not already listed in its known_hosts files.
+type HostKeyPolicy int
+
+// HostKeyPolicies that may be set on a Host.
+const (
+	// HostKeyStrict rejects any host key that is not already listed in the known_hosts files.
+	HostKeyStrict HostKeyPolicy = iota
+
+	// HostKeyAcceptNew accepts the host key of any host not yet listed in the known_hosts files,
+	// and appends it to the first known_hosts file with a hashed hostname, i.e. trust on first use.
+	// A host key that differs from the one listed is still rejected.
+	HostKeyAcceptNew
+)
+
+func (p HostKeyPolicy) String() string {
+	switch p {
+	case HostKeyStrict:
+		return "strict"
+	case HostKeyAcceptNew:
+		return "accept-new"
+	}
+
+	return fmt.Sprintf("HostKeyPolicy(%d)", int(p))
+}
+
+// ParseHostKeyPolicy parses a HostKeyPolicy from its name, or from a value of StrictHostKeyChecking from ssh_config(5).
+//
+// As host keys that have changed are always rejected, the values "no" and "off" are the same as "accept-new".
+func ParseHostKeyPolicy(s string) (HostKeyPolicy, error) {
+	switch strings.ToLower(s) {
+	case "strict", "yes", "ask":
+		return HostKeyStrict, nil
+	case "accept-new", "no", "off":
+		return HostKeyAcceptNew, nil
+	}
+
+	return HostKeyStrict, fmt.Errorf("unknown host key policy: %q", s)
+}
+
+// HostKeyError is returned when a host key offered by a host is not trusted.
+type HostKeyError struct {
+	// Hostname is the address of the host, as given to the ssh.HostKeyCallback.
+	Hostname string
+
+	// Key is the host key offered by the host, and Fingerprint is its SHA256 fingerprint.
+	Key         ssh.PublicKey
+	Fingerprint string
+
+	// Changed is true if a different host key is already known for the host.
+	Changed bool
+
+	// Err is the underlying reason that the host key is not trusted, if any.
+	Err error
+}
+
+func (e *HostKeyError) Error() string {
+	msg := fmt.Sprintf("host key for %s is not known: %s %s", e.Hostname, e.Key.Type(), e.Fingerprint)
+	if e.Changed {
+		msg = fmt.Sprintf("host key for %s has changed: %s %s", e.Hostname, e.Key.Type(), e.Fingerprint)
+	}
+
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+
+	return msg
+}
+
+// Unwrap returns the underlying error.
+func (e *HostKeyError) Unwrap() error {
+	return e.Err
+}
+
+func newHostKeyError(hostname string, key ssh.PublicKey, err error) *HostKeyError {
+	return &HostKeyError{
+		Hostname:    hostname,
+		Key:         key,
+		Fingerprint: ssh.FingerprintSHA256(key),
+		Err:         err,
+	}
+}
+
+// matchFingerprint returns true if the fingerprint is of the given key.
+// Fingerprints are in the form printed by ssh-keygen -l, i.e. SHA256:base64, or MD5:hex.
+func matchFingerprint(fingerprint string, key ssh.PublicKey) bool {
+	if strings.HasPrefix(fingerprint, "MD5:") {
+		return strings.EqualFold(strings.TrimPrefix(fingerprint, "MD5:"), ssh.FingerprintLegacyMD5(key))
+	}
+
+	// ssh.FingerprintSHA256 omits the base64 padding.
+	return strings.TrimRight(fingerprint, "=") == ssh.FingerprintSHA256(key)
+}
+
+// pinnedHostKey returns an ssh.HostKeyCallback that only accepts host keys matching one of the given fingerprints.
+func pinnedHostKey(fingerprints []string) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		for _, fingerprint := range fingerprints {
+			if matchFingerprint(fingerprint, key) {
+				return nil
+			}
+		}
+
+		err := newHostKeyError(hostname, key, errors.New("does not match pinned fingerprint"))
+		err.Changed = true
+		return err
+	}
+}
+
+// loadKnownHosts reads all of the given known_hosts files that exist.
+func loadKnownHosts(filenames []string) (ssh.HostKeyCallback, error) {
+	var existing []string
+
+	for _, filename := range filenames {
+		if _, err := os.Stat(filename); err == nil {
+			existing = append(existing, filename)
+		}
+	}
+
+	return knownhosts.New(existing...)
+}
+
+// knownAlgorithms returns the host key algorithms for the types of keys listed in known_hosts for the address,
+// so that the host is asked for a key that can actually be verified.
+func knownAlgorithms(db ssh.HostKeyCallback, address string) []string {
+	// Probing with a key that will never be known returns all the keys known for the address.
+	probe, err := ssh.NewPublicKey(ed25519.PublicKey(make([]byte, ed25519.PublicKeySize)))
+	if err != nil {
+		return nil
+	}
+
+	var keyErr *knownhosts.KeyError
+	if err := db(address, &net.TCPAddr{IP: net.IPv4zero}, probe); !errors.As(err, &keyErr) {
+		return nil
+	}
+
+	var algos []string
+
+	for _, known := range keyErr.Want {
+		switch typ := known.Key.Type(); typ {
+		case ssh.KeyAlgoRSA:
+			algos = append(algos, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA)
+		default:
+			algos = append(algos, typ)
+		}
+	}
+
+	return algos
+}
+
+var knownHostsMu sync.Mutex
+
+// appendKnownHost appends the key for the hostname to the known_hosts file, with the hostname hashed.
+func appendKnownHost(filename, hostname string, key ssh.PublicKey) error {
+	knownHostsMu.Lock()
+	defer knownHostsMu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(filename, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+
+	line := knownhosts.Line([]string{knownhosts.HashHostname(knownhosts.Normalize(hostname))}, key) + "\n"
+
+	// Do not join our line onto the end of a last line that has no newline.
+	if fi, err := f.Stat(); err == nil && fi.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, fi.Size()-1); err == nil && last[0] != '\n' {
+			line = "\n" + line
+		}
+	}
+
+	if _, err := f.WriteString(line); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// verifyKnownHost returns an ssh.HostKeyCallback that validates host keys against the known_hosts database,
+// and applies the given policy to hosts that are not yet known.
+func verifyKnownHost(db ssh.HostKeyCallback, policy HostKeyPolicy, filename string) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := db(hostname, remote, key)
+		if err == nil {
+			return nil
+		}
+
+		hkErr := newHostKeyError(hostname, key, err)
+
+		var keyErr *knownhosts.KeyError
+		if !errors.As(err, &keyErr) {
+			// The key has been revoked, or the database is broken.
+			return hkErr
+		}
+
+		if len(keyErr.Want) > 0 {
+			hkErr.Changed = true
+			return hkErr
+		}
+
+		if policy != HostKeyAcceptNew || filename == "" {
+			return hkErr
+		}
+
+		if err := appendKnownHost(filename, hostname, key); err != nil {
+			hkErr.Err = err
+			return hkErr
+		}
+
+		return nil
+	}
+}
+
+// hostKeyCallback returns the ssh.HostKeyCallback and host key algorithms to use for a new connection.
+//
+// In order of precedence, host keys are: ignored, checked against pinned fingerprints,
+// checked by an explicitly set callback, or else checked against the known_hosts files according to the HostKeyPolicy.
+//
+// Caller MUST hold the lock.
+func (h *Host) hostKeyCallback() (ssh.HostKeyCallback, []string, error) {
+	switch {
+	case h.ignoreHostkey:
+		return ssh.InsecureIgnoreHostKey(), h.hostkeyAlgos, nil
+
+	case len(h.fingerprints) > 0:
+		return pinnedHostKey(h.fingerprints), h.hostkeyAlgos, nil
+
+	case h.hostkey != nil:
+		return h.hostkey, h.hostkeyAlgos, nil
+
+	case len(h.knownHosts) < 1:
+		return nil, nil, errors.New("no hostkey validation defined")
+	}
+
+	// Read the files for every connection, so that keys added since are known.
+	db, err := loadKnownHosts(h.knownHosts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	algos := h.hostkeyAlgos
+	if len(algos) < 1 {
+		algos = knownAlgorithms(db, h.uri.Host)
+	}
+
+	return verifyKnownHost(db, h.hostkeyPolicy, h.knownHosts[0]), algos, nil
+}
+
+// SetHostKeyPolicy sets how the Host treats host keys not already listed in its known_hosts files, and returns the previous value.
+func (h *Host) SetHostKeyPolicy(policy HostKeyPolicy) HostKeyPolicy {
+	save := h.hostkeyPolicy
+
+	h.hostkeyPolicy = policy
+
+	return save
+}
+
+// SetKnownHostsFiles sets the known_hosts files used to validate host keys, and returns the previous value.
+// New host keys accepted under the HostKeyAcceptNew policy are appended to the first file.
+func (h *Host) SetKnownHostsFiles(filenames []string) []string {
+	save := h.knownHosts
+
+	h.knownHosts = filenames
+
+	return save
+}
+
+// SetHostKeyFingerprints sets the fingerprints of the only host keys that the Host accepts, and returns the previous value.
+// Fingerprints are in the form printed by ssh-keygen -l, e.g. SHA256:base64.
+//
+// If any fingerprints are set, then the known_hosts files and any hostkey callback are not used.
+func (h *Host) SetHostKeyFingerprints(fingerprints []string) []string {
+	save := h.fingerprints
+
+	h.fingerprints = fingerprints
+
+	return save
+}
@@ -0,0 +1,171 @@
+package sftpfiles
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func TestHostKeyPolicy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sftpfiles")
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := newTestServer(t)
+	defer s.Close()
+
+	knownHosts := filepath.Join(dir, "ssh", "known_hosts")
+
+	h := s.host()
+	defer h.Close()
+
+	_, _ = h.SetHostKeyCallback(nil, nil)
+	_ = h.SetKnownHostsFiles([]string{knownHosts})
+
+	_, err = h.Connect()
+
+	var hkErr *HostKeyError
+	if !errors.As(err, &hkErr) {
+		t.Fatalf("got error %v, expected a HostKeyError", err)
+	}
+
+	if expected := ssh.FingerprintSHA256(s.hostKey.PublicKey()); hkErr.Fingerprint != expected {
+		t.Errorf("got fingerprint %q, expected %q", hkErr.Fingerprint, expected)
+	}
+
+	if hkErr.Changed {
+		t.Error("unknown host reported as a changed host key")
+	}
+
+	_ = h.SetHostKeyPolicy(HostKeyAcceptNew)
+
+	if _, err := h.Connect(); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	b, err := ioutil.ReadFile(knownHosts)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if !strings.HasPrefix(string(b), "|1|") {
+		t.Errorf("expected a hashed hostname in known_hosts, got %q", b)
+	}
+
+	if strings.Contains(string(b), "127.0.0.1") {
+		t.Errorf("expected hostname to not appear in known_hosts, got %q", b)
+	}
+
+	_ = h.Close()
+	_ = h.SetHostKeyPolicy(HostKeyStrict)
+
+	if _, err := h.Connect(); err != nil {
+		t.Fatal("unexpected error after key was recorded", err)
+	}
+
+	_ = h.Close()
+
+	// Record a different host key for the same address.
+	s2 := newTestServer(t)
+	defer s2.Close()
+
+	line := knownhosts.Line([]string{knownhosts.Normalize(s.l.Addr().String())}, s2.hostKey.PublicKey())
+	if err := ioutil.WriteFile(knownHosts, []byte(line+"\n"), 0600); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	_ = h.SetHostKeyPolicy(HostKeyAcceptNew)
+
+	var changed *HostKeyError
+	if _, err := h.Connect(); !errors.As(err, &changed) || !changed.Changed {
+		t.Fatalf("got error %v, expected a changed host key", err)
+	}
+}
+
+func TestHostKeyFingerprint(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	h := s.host()
+	defer h.Close()
+
+	_, _ = h.SetHostKeyCallback(nil, nil)
+
+	_ = h.SetHostKeyFingerprints([]string{"SHA256:bm90IHRoZSByaWdodCBmaW5nZXJwcmludA"})
+
+	var hkErr *HostKeyError
+	if _, err := h.Connect(); !errors.As(err, &hkErr) {
+		t.Fatalf("got error %v, expected a HostKeyError", err)
+	}
+
+	_ = h.SetHostKeyFingerprints([]string{ssh.FingerprintSHA256(s.hostKey.PublicKey())})
+
+	if _, err := h.Connect(); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+}
+
+func TestHostKeyQueryScope(t *testing.T) {
+	fs := &filesystem{
+		hosts: make(map[string]*Host),
+	}
+	fs.once.Do(func() {})
+
+	getHost := func(s string) *Host {
+		t.Helper()
+
+		uri, err := url.Parse(s)
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+
+		h, err := fs.getHost(uri)
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+
+		return h
+	}
+
+	pinned := getHost("sftp://user@storage/?fingerprint=SHA256:pinned")
+	acceptNew := getHost("sftp://user@storage/?hostkey=accept-new")
+	plain := getHost("sftp://user@storage/")
+
+	if plain == pinned || plain == acceptNew || pinned == acceptNew {
+		t.Fatal("expected URLs with different host key settings to get their own Hosts")
+	}
+
+	if plain.hostkeyPolicy != HostKeyStrict {
+		t.Errorf("got policy %v on a Host for a URL without any, expected %v", plain.hostkeyPolicy, HostKeyStrict)
+	}
+
+	if len(plain.fingerprints) != 0 {
+		t.Errorf("got fingerprints %q on a Host for a URL without any, expected none", plain.fingerprints)
+	}
+
+	if got := getHost("sftp://user@storage/other?fingerprint=SHA256:other"); got == pinned {
+		t.Error("expected URLs with different pinned fingerprints to get their own Hosts")
+	}
+
+	if got := getHost("sftp://user@storage/other?fingerprint=SHA256:pinned"); got != pinned {
+		t.Error("expected URLs with the same pinned fingerprints to share a Host")
+	}
+
+	if got := getHost("sftp://user@storage/other?hostkey=no"); got != acceptNew {
+		t.Error("expected URLs with the same host key policy to share a Host")
+	}
+
+	uri, _ := url.Parse("sftp://user@storage/?hostkey=bogus")
+	if _, err := fs.getHost(uri); err == nil {
+		t.Error("expected an error for an invalid host key policy, got none")
+	}
+}
@@ -0,0 +1,153 @@
+package sftpfiles
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/puellanivis/breton/lib/os/user"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// PassphraseFunc returns the passphrase to decrypt the private key in the given file.
+type PassphraseFunc func(filename string) ([]byte, error)
+
+// defaultIdentityFiles are the files in ~/.ssh that are tried when no identity files are given, in the same order as ssh(1).
+var defaultIdentityFiles = []string{
+	"id_rsa",
+	"id_ecdsa",
+	"id_ed25519",
+	"id_dsa",
+}
+
+func defaultIdentities() []string {
+	home, err := user.CurrentHomeDir()
+	if err != nil {
+		return nil
+	}
+
+	var filenames []string
+	for _, name := range defaultIdentityFiles {
+		filenames = append(filenames, filepath.Join(home, ".ssh", name))
+	}
+
+	return filenames
+}
+
+// loadIdentity reads a private key from the given file.
+// If the key is encrypted, the passphrase is obtained from the given PassphraseFunc.
+func loadIdentity(filename string, passphrase PassphraseFunc) (ssh.Signer, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := ssh.ParsePrivateKey(b)
+
+	var missing *ssh.PassphraseMissingError
+	if !errors.As(err, &missing) {
+		return signer, err
+	}
+
+	if passphrase == nil {
+		return nil, err
+	}
+
+	pass, err := passphrase(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return ssh.ParsePrivateKeyWithPassphrase(b, pass)
+}
+
+// loadCertificate reads an OpenSSH certificate from the given file.
+func loadCertificate(filename string) (*ssh.Certificate, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	key, _, _, _, err := ssh.ParseAuthorizedKey(b)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, ok := key.(*ssh.Certificate)
+	if !ok {
+		return nil, errors.New("not an OpenSSH certificate")
+	}
+
+	return cert, nil
+}
+
+// identitySigners loads the identity files of the Host, and returns a signer for each,
+// preceded by a certificate signer for each certificate of that key.
+// Keys are cached, so that passphrases are only requested once.
+//
+// Caller MUST hold the lock.
+func (h *Host) identitySigners() []ssh.Signer {
+	identities := h.identities
+	if len(identities) < 1 {
+		identities = defaultIdentities()
+	}
+
+	var certs []*ssh.Certificate
+
+	// Like ssh(1), a certificate is looked for next to each identity file.
+	for _, filename := range append(h.certificates, mapSuffix(identities, "-cert.pub")...) {
+		if cert, err := loadCertificate(filename); err == nil {
+			certs = append(certs, cert)
+		}
+	}
+
+	if h.keys == nil {
+		h.keys = make(map[string]ssh.Signer)
+	}
+
+	var signers []ssh.Signer
+
+	for _, filename := range identities {
+		signer := h.keys[filename]
+
+		if signer == nil {
+			var err error
+
+			signer, err = loadIdentity(filename, h.passphrase)
+			if err != nil {
+				// Like ssh(1), missing or unusable identity files are skipped.
+				continue
+			}
+
+			h.keys[filename] = signer
+		}
+
+		pub := signer.PublicKey().Marshal()
+
+		for _, cert := range certs {
+			if !bytes.Equal(cert.Key.Marshal(), pub) {
+				continue
+			}
+
+			if certSigner, err := ssh.NewCertSigner(cert, signer); err == nil {
+				signers = append(signers, certSigner)
+			}
+		}
+
+		signers = append(signers, signer)
+	}
+
+	return signers
+}
+
+func mapSuffix(filenames []string, suffix string) []string {
+	var out []string
+
+	for _, filename := range filenames {
+		out = append(out, filename+suffix)
+	}
+
+	return out
+}
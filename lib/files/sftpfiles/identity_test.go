@@ -0,0 +1,157 @@
+package sftpfiles
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestIdentitySigners(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sftpfiles")
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	defer os.RemoveAll(dir)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	block, err := x509.EncryptPEMBlock(rand.Reader, "EC PRIVATE KEY", der, []byte("hunter2"), x509.PEMCipherAES256)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	filename := filepath.Join(dir, "id_ecdsa")
+	if err := ioutil.WriteFile(filename, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	pub, err := ssh.NewPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	ca, err := ssh.NewSignerFromKey(caKey)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	cert := &ssh.Certificate{
+		Key:             pub,
+		CertType:        ssh.UserCert,
+		ValidPrincipals: []string{"user"},
+		ValidBefore:     ssh.CertTimeInfinity,
+	}
+	if err := cert.SignCert(rand.Reader, ca); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if err := ioutil.WriteFile(filename+"-cert.pub", ssh.MarshalAuthorizedKey(cert), 0600); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	h := new(Host)
+	_ = h.AddIdentityFile(filename)
+	_ = h.AddIdentityFile(filepath.Join(dir, "id_missing"))
+
+	if signers := h.identitySigners(); len(signers) != 0 {
+		t.Errorf("got %d signers without a passphrase, expected 0", len(signers))
+	}
+
+	var prompts int
+	_ = h.SetPassphraseCallback(func(string) ([]byte, error) {
+		prompts++
+		return []byte("hunter2"), nil
+	})
+
+	for i := 0; i < 2; i++ {
+		signers := h.identitySigners()
+		if len(signers) != 2 {
+			t.Fatalf("got %d signers, expected 2", len(signers))
+		}
+
+		if _, ok := signers[0].PublicKey().(*ssh.Certificate); !ok {
+			t.Errorf("expected first signer to be a certificate, got %s", signers[0].PublicKey().Type())
+		}
+
+		if got := signers[1].PublicKey().Marshal(); string(got) != string(pub.Marshal()) {
+			t.Error("expected second signer to be the plain key")
+		}
+	}
+
+	if prompts != 1 {
+		t.Errorf("got %d passphrase prompts, expected 1", prompts)
+	}
+
+	_ = h.SetPassphraseCallback(func(string) ([]byte, error) {
+		return nil, errors.New("no passphrase")
+	})
+
+	if signers := h.identitySigners(); len(signers) != 0 {
+		t.Errorf("got %d signers with a failing passphrase callback, expected 0", len(signers))
+	}
+}
+
+func TestIdentityQueryScope(t *testing.T) {
+	fs := &filesystem{
+		hosts: make(map[string]*Host),
+	}
+	fs.once.Do(func() {})
+
+	getHost := func(s string) *Host {
+		t.Helper()
+
+		uri, err := url.Parse(s)
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+
+		h, err := fs.getHost(uri)
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+
+		return h
+	}
+
+	plain := getHost("sftp://user@storage/")
+	withIdentity := getHost("sftp://user@storage/?identity=/keys/id_test")
+
+	if plain == withIdentity {
+		t.Fatal("expected a URL with an identity to get its own Host")
+	}
+
+	if len(plain.identities) != 0 {
+		t.Errorf("got identities %q on a Host for a URL without any, expected none", plain.identities)
+	}
+
+	if got := getHost("sftp://user@storage/other?identity=/keys/id_test"); got != withIdentity {
+		t.Error("expected URLs with the same identity to share a Host")
+	}
+
+	if got := getHost("sftp://user@storage/other"); got != plain {
+		t.Error("expected URLs without identities to share a Host")
+	}
+}
@@ -0,0 +1,67 @@
+package sftpfiles
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestJumpHosts(t *testing.T) {
+	c, err := ParseConfig(strings.NewReader(`
+Host storage*
+	ProxyJump jump@bastion:2222
+
+Host bastion
+	HostName bastion.example.com
+`), "")
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	fs := &filesystem{
+		config: c,
+		hosts:  make(map[string]*Host),
+	}
+
+	lookup := func(s, jump string) *Host {
+		t.Helper()
+
+		uri, err := url.Parse(s)
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+
+		h, err := fs.lookupHost(uri, jump, 0, nil)
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+
+		return h
+	}
+
+	h1 := lookup("sftp://user@storage1/", "")
+	h2 := lookup("sftp://user@storage2/", "")
+
+	if h1.jump == nil || h1.jump != h2.jump {
+		t.Fatal("expected both hosts to share the same jump host")
+	}
+
+	if got, expected := h1.jump.Name(), "ssh://jump@bastion.example.com:2222"; got != expected {
+		t.Errorf("got jump host %q, expected %q", got, expected)
+	}
+
+	h3 := lookup("sftp://user@target/", "first,ssh://second:2022")
+
+	var chain []string
+	for j := h3.jump; j != nil; j = j.jump {
+		chain = append(chain, j.uri.Host)
+	}
+
+	if got, expected := strings.Join(chain, ","), "second:2022,first:22"; got != expected {
+		t.Errorf("got jump chain %q, expected %q", got, expected)
+	}
+
+	if h := lookup("sftp://user@storage1/", "none"); h.jump != nil || h == h1 {
+		t.Error("expected a direct host to be distinct from a jumped host")
+	}
+}
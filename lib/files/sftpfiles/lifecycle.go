@@ -0,0 +1,187 @@
+package sftpfiles
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Defaults for the connection lifecycle of a Host.
+const (
+	DefaultKeepaliveInterval = 30 * time.Second
+	DefaultKeepaliveCount    = 3
+	DefaultIdleTimeout       = 5 * time.Minute
+	DefaultMaxRequests       = 64
+)
+
+// watch waits for the connection to end, and then invalidates it, if it is still the current connection of the Host.
+func (h *Host) watch(conn *ssh.Client, dead chan struct{}) {
+	_ = conn.Wait()
+	close(dead)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.conn == conn {
+		_ = h.close(reasonDead)
+	}
+}
+
+// keepalive sends a keepalive request on the connection at every interval,
+// and closes the connection if count requests in a row go unanswered.
+func (h *Host) keepalive(conn *ssh.Client, dead <-chan struct{}, interval time.Duration, count int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var missed int
+
+	for {
+		select {
+		case <-dead:
+			return
+		case <-ticker.C:
+		}
+
+		reply := make(chan error, 1)
+		go func() {
+			_, _, err := conn.SendRequest("keepalive@openssh.com", true, nil)
+			reply <- err
+		}()
+
+		select {
+		case <-dead:
+			return
+
+		case err := <-reply:
+			if err == nil {
+				missed = 0
+				continue
+			}
+
+		case <-time.After(interval):
+		}
+
+		if missed++; missed < count {
+			continue
+		}
+
+		h.mu.Lock()
+		if h.conn == conn {
+			_ = h.close(reasonKeepalive)
+		}
+		h.mu.Unlock()
+
+		return
+	}
+}
+
+// use marks the Host as in use, which prevents it from being closed as idle.
+// It returns a function that marks the use as over, which may be safely called more than once.
+func (h *Host) use() (release func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.active++
+
+	if h.idle != nil {
+		h.idle.Stop()
+		h.idle = nil
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(h.release)
+	}
+}
+
+func (h *Host) release() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.active--
+
+	if h.active > 0 || h.idleTimeout <= 0 {
+		return
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(h.idleTimeout, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		if h.idle != timer || h.active > 0 {
+			// Used again since this timer was set.
+			return
+		}
+
+		h.idle = nil
+
+		if h.conn != nil {
+			_ = h.close(reasonIdle)
+		}
+	})
+
+	h.idle = timer
+}
+
+// limit waits until the number of concurrent requests to the Host is below its maximum,
+// and returns a function to be called when the request is complete.
+func (h *Host) limit() (done func()) {
+	sem := h.sem
+
+	if sem != nil {
+		sem <- struct{}{}
+	}
+
+	h.metrics.inflight.Inc()
+
+	return func() {
+		h.metrics.inflight.Dec()
+
+		if sem != nil {
+			<-sem
+		}
+	}
+}
+
+// SetKeepalive sets the interval between keepalive requests sent to the Host,
+// and how many may go unanswered in a row before the connection is considered dead, and returns the previous values.
+//
+// If the interval is less than or equal to zero, then no keepalive requests are sent.
+// This only takes effect for new connections.
+func (h *Host) SetKeepalive(interval time.Duration, count int) (time.Duration, int) {
+	saveInterval, saveCount := h.keepaliveInterval, h.keepaliveCount
+
+	h.keepaliveInterval = interval
+	h.keepaliveCount = count
+
+	return saveInterval, saveCount
+}
+
+// SetIdleTimeout sets how long the connection of the Host is kept open, after it is no longer used, and returns the previous value.
+//
+// If the timeout is less than or equal to zero, then the connection is never closed for being idle.
+func (h *Host) SetIdleTimeout(timeout time.Duration) time.Duration {
+	save := h.idleTimeout
+
+	h.idleTimeout = timeout
+
+	return save
+}
+
+// SetMaxRequests sets the maximum number of concurrent SFTP requests made to the Host, and returns the previous value.
+//
+// If the maximum is less than or equal to zero, then there is no limit.
+func (h *Host) SetMaxRequests(max int) int {
+	save := h.maxRequests
+
+	h.maxRequests = max
+
+	h.sem = nil
+	if max > 0 {
+		h.sem = make(chan struct{}, max)
+	}
+
+	return save
+}
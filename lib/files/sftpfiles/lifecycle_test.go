@@ -0,0 +1,113 @@
+package sftpfiles
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestReconnect(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	h := s.host()
+	defer h.Close()
+
+	cl, err := h.Connect()
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	s.dropAll()
+
+	// Wait for the dropped connection to be noticed.
+	deadline := time.Now().Add(5 * time.Second)
+	for h.GetClient() != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("dropped connection was never invalidated")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cl2, err := h.Connect()
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if cl2 == cl {
+		t.Fatal("expected a new client after reconnecting")
+	}
+
+	if _, err := cl2.Getwd(); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+}
+
+func TestIdleTimeout(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	h := s.host()
+	defer h.Close()
+
+	_ = h.SetIdleTimeout(50 * time.Millisecond)
+
+	release := h.use()
+
+	if _, err := h.Connect(); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	if h.GetClient() == nil {
+		t.Fatal("connection was closed while in use")
+	}
+
+	release()
+	release() // releasing more than once must be harmless.
+
+	time.Sleep(200 * time.Millisecond)
+
+	if h.GetClient() != nil {
+		t.Fatal("expected idle connection to be closed")
+	}
+
+	h.mu.Lock()
+	active := h.active
+	h.mu.Unlock()
+
+	if active != 0 {
+		t.Errorf("got %d active uses, expected 0", active)
+	}
+}
+
+func TestMaxRequests(t *testing.T) {
+	h := NewHost(&url.URL{Host: "localhost"})
+	_ = h.SetMaxRequests(1)
+
+	done := h.limit()
+
+	acquired := make(chan struct{})
+	go func() {
+		done := h.limit()
+		defer done()
+
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("second request was not limited")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	done()
+
+	select {
+	case <-acquired:
+	case <-time.After(5 * time.Second):
+		t.Fatal("second request never proceeded")
+	}
+}
@@ -0,0 +1,48 @@
+package sftpfiles
+
+import (
+	"github.com/puellanivis/breton/lib/metrics"
+)
+
+const (
+	hostLabel   = metrics.Label("host")
+	reasonLabel = metrics.Label("reason")
+)
+
+// Reasons that a connection was closed.
+const (
+	reasonClosed    = "closed"
+	reasonDead      = "dead"
+	reasonIdle      = "idle"
+	reasonKeepalive = "keepalive"
+)
+
+type metricsPack struct {
+	connects      *metrics.CounterValue
+	connectErrors *metrics.CounterValue
+	disconnects   *metrics.CounterValue
+	connected     *metrics.GaugeValue
+	inflight      *metrics.GaugeValue
+}
+
+var baseMetrics = &metricsPack{
+	connects:      metrics.Counter("sftpfiles_connects_total", "number of ssh connections established", metrics.WithLabels(hostLabel)),
+	connectErrors: metrics.Counter("sftpfiles_connect_errors_total", "number of failed attempts to establish an ssh connection", metrics.WithLabels(hostLabel)),
+	disconnects:   metrics.Counter("sftpfiles_disconnects_total", "number of ssh connections closed, by the reason they were closed", metrics.WithLabels(hostLabel, reasonLabel)),
+	connected:     metrics.Gauge("sftpfiles_connected", "whether an ssh connection to the host is currently established", metrics.WithLabels(hostLabel)),
+	inflight:      metrics.Gauge("sftpfiles_requests_in_flight", "number of sftp requests to the host currently in progress", metrics.WithLabels(hostLabel)),
+}
+
+func (m *metricsPack) WithLabels(labels ...metrics.Labeler) *metricsPack {
+	return &metricsPack{
+		connects:      m.connects.WithLabels(labels...),
+		connectErrors: m.connectErrors.WithLabels(labels...),
+		disconnects:   m.disconnects.WithLabels(labels...),
+		connected:     m.connected.WithLabels(labels...),
+		inflight:      m.inflight.WithLabels(labels...),
+	}
+}
+
+func (m *metricsPack) disconnected(reason string) {
+	m.disconnects.WithLabels(reasonLabel.WithValue(reason)).Inc()
+}
@@ -0,0 +1,77 @@
+package sftpfiles
+
+import (
+	"context"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestMkdirAll(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sftpfiles")
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := newTestServer(t)
+	defer s.Close()
+
+	fs := &filesystem{
+		hosts: make(map[string]*Host),
+	}
+	fs.once.Do(func() {})
+
+	uri := &url.URL{
+		Scheme: "sftp",
+		Host:   s.l.Addr().String(),
+		User:   url.UserPassword("user", "pass"),
+		Path:   filepath.ToSlash(filepath.Join(dir, "a", "b", "c")),
+	}
+
+	h, err := fs.getHost(uri)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	defer h.Close()
+
+	_, _ = h.SetHostKeyCallback(ssh.FixedHostKey(s.hostKey.PublicKey()), nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := fs.MkdirAll(ctx, uri, 0750); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	for _, name := range []string{"a", "a/b", "a/b/c"} {
+		fi, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+
+		if !fi.IsDir() || fi.Mode().Perm() != 0750 {
+			t.Errorf("%s: got mode %v, expected a directory with %v", name, fi.Mode(), os.FileMode(0750))
+		}
+	}
+
+	// Making a directory that already exists does nothing.
+	if err := fs.MkdirAll(ctx, uri, 0700); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "file"), nil, 0644); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	uri.Path = filepath.ToSlash(filepath.Join(dir, "file", "d"))
+
+	if err := fs.MkdirAll(ctx, uri, 0750); err == nil {
+		t.Error("expected an error making a directory under a file, got none")
+	}
+}
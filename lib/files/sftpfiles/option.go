@@ -1,6 +1,8 @@
 package sftpfiles
 
 import (
+	"time"
+
 	"github.com/puellanivis/breton/lib/files"
 
 	"golang.org/x/crypto/ssh"
@@ -95,3 +97,152 @@ func WithHostKey(hostkey []byte) files.Option {
 
 	return withHostKeyCallback(ssh.FixedHostKey(key), []string{key.Type()})
 }
+
+func withIdentityFiles(filenames []string) files.Option {
+	type identitySetter interface {
+		SetIdentityFiles([]string) []string
+	}
+
+	return func(f files.File) (files.Option, error) {
+		h, ok := f.(identitySetter)
+		if !ok {
+			return noopOption(), nil
+		}
+
+		save := h.SetIdentityFiles(filenames)
+		return withIdentityFiles(save), nil
+	}
+}
+
+// WithIdentityFile includes a private key file to be used for public key authentication during the ssh.Dial.
+//
+// If a certificate file exists with the same filename and "-cert.pub" appended, then it is also used.
+func WithIdentityFile(filename string) files.Option {
+	type identityAdder interface {
+		AddIdentityFile(string) []string
+	}
+
+	return func(f files.File) (files.Option, error) {
+		h, ok := f.(identityAdder)
+		if !ok {
+			return noopOption(), nil
+		}
+
+		save := h.AddIdentityFile(filename)
+		return withIdentityFiles(save), nil
+	}
+}
+
+// WithPassphrase defines a callback to obtain the passphrase of any encrypted private key file.
+func WithPassphrase(fn PassphraseFunc) files.Option {
+	type passphraseSetter interface {
+		SetPassphraseCallback(PassphraseFunc) PassphraseFunc
+	}
+
+	return func(f files.File) (files.Option, error) {
+		h, ok := f.(passphraseSetter)
+		if !ok {
+			return noopOption(), nil
+		}
+
+		save := h.SetPassphraseCallback(fn)
+		return WithPassphrase(save), nil
+	}
+}
+
+// WithKeepalive sets the interval between keepalive requests, and how many may go unanswered in a row before the connection is considered dead.
+//
+// If the interval is less than or equal to zero, then no keepalive requests are sent.
+func WithKeepalive(interval time.Duration, count int) files.Option {
+	type keepaliveSetter interface {
+		SetKeepalive(time.Duration, int) (time.Duration, int)
+	}
+
+	return func(f files.File) (files.Option, error) {
+		h, ok := f.(keepaliveSetter)
+		if !ok {
+			return noopOption(), nil
+		}
+
+		saveInterval, saveCount := h.SetKeepalive(interval, count)
+		return WithKeepalive(saveInterval, saveCount), nil
+	}
+}
+
+// WithIdleTimeout sets how long a connection is kept open after it is no longer used.
+//
+// If the timeout is less than or equal to zero, then the connection is never closed for being idle.
+func WithIdleTimeout(timeout time.Duration) files.Option {
+	type idleTimeoutSetter interface {
+		SetIdleTimeout(time.Duration) time.Duration
+	}
+
+	return func(f files.File) (files.Option, error) {
+		h, ok := f.(idleTimeoutSetter)
+		if !ok {
+			return noopOption(), nil
+		}
+
+		save := h.SetIdleTimeout(timeout)
+		return WithIdleTimeout(save), nil
+	}
+}
+
+// WithMaxRequests sets the maximum number of concurrent SFTP requests made to a host.
+//
+// If the maximum is less than or equal to zero, then there is no limit.
+func WithMaxRequests(max int) files.Option {
+	type maxRequestsSetter interface {
+		SetMaxRequests(int) int
+	}
+
+	return func(f files.File) (files.Option, error) {
+		h, ok := f.(maxRequestsSetter)
+		if !ok {
+			return noopOption(), nil
+		}
+
+		save := h.SetMaxRequests(max)
+		return WithMaxRequests(save), nil
+	}
+}
+
+// WithHostKeyPolicy sets how host keys that are not already listed in the known_hosts files are treated.
+func WithHostKeyPolicy(policy HostKeyPolicy) files.Option {
+	type hostkeyPolicySetter interface {
+		SetHostKeyPolicy(HostKeyPolicy) HostKeyPolicy
+	}
+
+	return func(f files.File) (files.Option, error) {
+		h, ok := f.(hostkeyPolicySetter)
+		if !ok {
+			return noopOption(), nil
+		}
+
+		save := h.SetHostKeyPolicy(policy)
+		return WithHostKeyPolicy(save), nil
+	}
+}
+
+func withHostKeyFingerprints(fingerprints []string) files.Option {
+	type fingerprintSetter interface {
+		SetHostKeyFingerprints([]string) []string
+	}
+
+	return func(f files.File) (files.Option, error) {
+		h, ok := f.(fingerprintSetter)
+		if !ok {
+			return noopOption(), nil
+		}
+
+		save := h.SetHostKeyFingerprints(fingerprints)
+		return withHostKeyFingerprints(save), nil
+	}
+}
+
+// WithHostKeyFingerprint pins the host key to the one with the given fingerprint, in the form printed by ssh-keygen -l, e.g. SHA256:base64.
+//
+// If the IgnoreHostKeys option has been set, then this option will be ignored.
+func WithHostKeyFingerprint(fingerprint string) files.Option {
+	return withHostKeyFingerprints([]string{fingerprint})
+}
@@ -17,6 +17,8 @@ type reader struct {
 	loading <-chan struct{}
 	f       *sftp.File
 	err     error
+
+	releaseHost func()
 }
 
 func (r *reader) Name() string {
@@ -31,6 +33,9 @@ func (r *reader) Stat() (os.FileInfo, error) {
 		return nil, r.err
 	}
 
+	done := r.limit()
+	defer done()
+
 	return r.f.Stat()
 }
 
@@ -42,6 +47,9 @@ func (r *reader) Read(b []byte) (n int, err error) {
 		return 0, r.err
 	}
 
+	done := r.limit()
+	defer done()
+
 	return r.f.Read(b)
 }
 
@@ -53,6 +61,9 @@ func (r *reader) Seek(offset int64, whence int) (int64, error) {
 		return 0, r.err
 	}
 
+	done := r.limit()
+	defer done()
+
 	return r.f.Seek(offset, whence)
 }
 
@@ -60,28 +71,29 @@ func (r *reader) Close() error {
 	for range r.loading {
 	}
 
+	defer r.releaseHost()
+
 	if r.err != nil {
 		// This error is a connection error, and request-scoped.
 		// So, in the context of Close, the error is irrelevant, so we ignore it.
 		return nil
 	}
 
+	done := r.limit()
+	defer done()
+
 	return r.f.Close()
 }
 
 func (fs *filesystem) Open(ctx context.Context, uri *url.URL) (files.Reader, error) {
-	h := fs.getHost(uri)
-
-	if cl := h.GetClient(); cl != nil {
-		f, err := cl.Open(uri.Path)
-		if err != nil {
-			return nil, files.PathError("open", uri.String(), err)
-		}
-
-		return f, nil
+	h, err := fs.getHost(uri)
+	if err != nil {
+		return nil, files.PathError("connect", uri.String(), err)
 	}
 
-	loading := make(chan struct{})
+	if uri.Scheme == "scp" {
+		return fs.openSCP(ctx, h, uri)
+	}
 
 	fixURL := *uri
 	fixURL.Host = h.uri.Host
@@ -91,9 +103,31 @@ func (fs *filesystem) Open(ctx context.Context, uri *url.URL) (files.Reader, err
 		uri:  &fixURL,
 		Host: h,
 
-		loading: loading,
+		releaseHost: h.use(),
 	}
 
+	if cl := h.GetClient(); cl != nil {
+		done := h.limit()
+		f, err := cl.Open(uri.Path)
+		done()
+
+		if err != nil {
+			r.releaseHost()
+			return nil, files.PathError("open", uri.String(), err)
+		}
+
+		loaded := make(chan struct{})
+		close(loaded)
+
+		r.loading = loaded
+		r.f = f
+
+		return r, nil
+	}
+
+	loading := make(chan struct{})
+	r.loading = loading
+
 	go func() {
 		defer close(loading)
 
@@ -110,6 +144,9 @@ func (fs *filesystem) Open(ctx context.Context, uri *url.URL) (files.Reader, err
 			return
 		}
 
+		done := h.limit()
+		defer done()
+
 		f, err := cl.Open(uri.Path)
 		if err != nil {
 			r.err = files.PathError("open", r.Name(), err)
@@ -0,0 +1,427 @@
+package sftpfiles
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/puellanivis/breton/lib/files"
+	"github.com/puellanivis/breton/lib/files/wrapper"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// scpError is an error reported by the remote scp.
+type scpError string
+
+func (e scpError) Error() string {
+	return string(e)
+}
+
+// Is allows errors.Is(err, os.ErrNotExist) to work for a remote file that does not exist.
+func (e scpError) Is(target error) bool {
+	return target == os.ErrNotExist && strings.Contains(string(e), "No such file or directory")
+}
+
+// shellQuote quotes a string for use as a single argument to a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// readAck reads a response from the remote scp, returning an error if it reported one.
+func readAck(r *bufio.Reader) error {
+	code, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+
+	switch code {
+	case 0:
+		return nil
+
+	case 1, 2:
+		msg, err := r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+
+		return scpError(strings.TrimSuffix(msg, "\n"))
+	}
+
+	return fmt.Errorf("scp: unexpected response: %q", code)
+}
+
+// session opens a new ssh.Session on the connection of the Host, connecting first if necessary.
+func (h *Host) session() (*ssh.Session, error) {
+	h.mu.Lock()
+	conn, err := h.sshClient()
+	h.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return conn.NewSession()
+}
+
+// scpTransfer is a running remote scp.
+type scpTransfer struct {
+	session *ssh.Session
+	w       io.WriteCloser
+	r       *bufio.Reader
+}
+
+func (h *Host) startSCP(args string, filename string) (*scpTransfer, error) {
+	session, err := h.session()
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := session.StdinPipe()
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+
+	r, err := session.StdoutPipe()
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+
+	if err := session.Start("scp " + args + " -- " + shellQuote(filename)); err != nil {
+		session.Close()
+		return nil, err
+	}
+
+	return &scpTransfer{
+		session: session,
+		w:       w,
+		r:       bufio.NewReader(r),
+	}, nil
+}
+
+func (t *scpTransfer) ack() error {
+	_, err := t.w.Write([]byte{0})
+	return err
+}
+
+// finish ends the transfer, and waits for the remote scp to exit.
+func (t *scpTransfer) finish() error {
+	_ = t.w.Close()
+
+	err := t.session.Wait()
+	_ = t.session.Close()
+
+	return err
+}
+
+// abort ends the transfer without waiting for the remote scp.
+func (t *scpTransfer) abort() {
+	_ = t.session.Close()
+}
+
+// receiveHeader reads the file header sent by the remote scp as a source, along with any preceding times.
+func (t *scpTransfer) receiveHeader(info *wrapper.Info) (size int64, err error) {
+	for {
+		line, err := t.r.ReadString('\n')
+		if err != nil {
+			return 0, err
+		}
+
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return 0, errors.New("scp: empty response")
+		}
+
+		switch line[0] {
+		case 1, 2:
+			return 0, scpError(line[1:])
+
+		case 'T':
+			// T<mtime> <mtime usec> <atime> <atime usec>
+			fields := strings.Fields(line[1:])
+			if len(fields) != 4 {
+				return 0, fmt.Errorf("scp: invalid times: %q", line)
+			}
+
+			mtime, err := strconv.ParseInt(fields[0], 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("scp: invalid times: %q", line)
+			}
+
+			info.SetModTime(time.Unix(mtime, 0))
+
+		case 'C':
+			// C<mode> <size> <name>
+			fields := strings.SplitN(line[1:], " ", 3)
+			if len(fields) != 3 {
+				return 0, fmt.Errorf("scp: invalid file header: %q", line)
+			}
+
+			mode, err := strconv.ParseUint(fields[0], 8, 32)
+			if err != nil {
+				return 0, fmt.Errorf("scp: invalid file mode: %q", line)
+			}
+
+			size, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil || size < 0 {
+				return 0, fmt.Errorf("scp: invalid file size: %q", line)
+			}
+
+			_ = info.Chmod(os.FileMode(mode) & os.ModePerm)
+			info.SetSize(int(size))
+
+			return size, t.ack()
+
+		case 'D':
+			return 0, errors.New("scp: is a directory")
+
+		default:
+			return 0, fmt.Errorf("scp: unexpected response: %q", line)
+		}
+
+		if err := t.ack(); err != nil {
+			return 0, err
+		}
+	}
+}
+
+type scpReader struct {
+	uri *url.URL
+	*Host
+
+	loading <-chan struct{}
+	info    *wrapper.Info
+	t       *scpTransfer
+	body    io.Reader
+	err     error
+
+	releaseHost func()
+}
+
+func (r *scpReader) Name() string {
+	return r.uri.String()
+}
+
+func (r *scpReader) Stat() (os.FileInfo, error) {
+	for range r.loading {
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	return r.info, nil
+}
+
+func (r *scpReader) Read(b []byte) (n int, err error) {
+	for range r.loading {
+	}
+
+	if r.err != nil {
+		return 0, r.err
+	}
+
+	n, err = r.body.Read(b)
+	if err != io.EOF {
+		return n, err
+	}
+
+	if r.t != nil {
+		// The content is followed by a status from the remote scp, which we must acknowledge.
+		t := r.t
+		r.t = nil
+
+		if err := readAck(t.r); err != nil {
+			t.abort()
+			return n, files.PathError("read", r.Name(), err)
+		}
+
+		if err := t.ack(); err != nil {
+			t.abort()
+			return n, files.PathError("read", r.Name(), err)
+		}
+
+		if err := t.finish(); err != nil {
+			return n, files.PathError("read", r.Name(), err)
+		}
+	}
+
+	return n, io.EOF
+}
+
+func (r *scpReader) Seek(offset int64, whence int) (int64, error) {
+	for range r.loading {
+	}
+
+	if r.err != nil {
+		return 0, r.err
+	}
+
+	return 0, os.ErrInvalid
+}
+
+func (r *scpReader) Close() error {
+	for range r.loading {
+	}
+
+	defer r.releaseHost()
+
+	if r.t != nil {
+		r.t.abort()
+		r.t = nil
+	}
+
+	return nil
+}
+
+// openSCP opens the remote file with the scp protocol, with the remote scp acting as the source.
+func (fs *filesystem) openSCP(ctx context.Context, h *Host, uri *url.URL) (files.Reader, error) {
+	fixURL := *uri
+	fixURL.Host = h.uri.Host
+	fixURL.User = h.uri.User
+
+	loading := make(chan struct{})
+
+	r := &scpReader{
+		uri:  &fixURL,
+		Host: h,
+
+		loading: loading,
+		info:    wrapper.NewInfo(&fixURL, 0, time.Now()),
+
+		releaseHost: h.use(),
+	}
+
+	go func() {
+		defer close(loading)
+
+		select {
+		case loading <- struct{}{}:
+		case <-ctx.Done():
+			r.err = files.PathError("connect", h.Name(), ctx.Err())
+			return
+		}
+
+		done := h.limit()
+		defer done()
+
+		t, err := h.startSCP("-f -p", uri.Path)
+		if err != nil {
+			r.err = files.PathError("connect", h.Name(), err)
+			return
+		}
+
+		// Signal that we are ready to receive.
+		if err := t.ack(); err != nil {
+			t.abort()
+			r.err = files.PathError("open", r.Name(), err)
+			return
+		}
+
+		size, err := t.receiveHeader(r.info)
+		if err != nil {
+			t.abort()
+			r.err = files.PathError("open", r.Name(), err)
+			return
+		}
+
+		r.t = t
+		r.body = io.LimitReader(t.r, size)
+	}()
+
+	return r, nil
+}
+
+// sendSCP writes the content to the remote file with the scp protocol, with the remote scp acting as the sink.
+func (h *Host) sendSCP(filename string, mode os.FileMode, mtime time.Time, b []byte) error {
+	done := h.limit()
+	defer done()
+
+	t, err := h.startSCP("-t -p", filename)
+	if err != nil {
+		return err
+	}
+
+	send := func(format string, args ...interface{}) error {
+		if _, err := fmt.Fprintf(t.w, format, args...); err != nil {
+			return err
+		}
+
+		return readAck(t.r)
+	}
+
+	if err := readAck(t.r); err != nil {
+		t.abort()
+		return err
+	}
+
+	if err := send("T%d 0 %d 0\n", mtime.Unix(), mtime.Unix()); err != nil {
+		t.abort()
+		return err
+	}
+
+	if err := send("C%04o %d %s\n", mode.Perm(), len(b), path.Base(filename)); err != nil {
+		t.abort()
+		return err
+	}
+
+	if _, err := t.w.Write(b); err != nil {
+		t.abort()
+		return err
+	}
+
+	if err := send("\x00"); err != nil {
+		t.abort()
+		return err
+	}
+
+	return t.finish()
+}
+
+// createSCP returns a files.Writer that buffers all writes, and sends them to the remote file with the scp protocol on every Sync and Close.
+//
+// The file is created with the mode set by files.WithFileMode, or 0644 by default.
+func (fs *filesystem) createSCP(ctx context.Context, h *Host, uri *url.URL) (files.Writer, error) {
+	fixURL := *uri
+	fixURL.Host = h.uri.Host
+	fixURL.User = h.uri.User
+
+	release := h.use()
+
+	var w *wrapper.Writer
+
+	w = wrapper.NewWriter(ctx, &fixURL, func(b []byte) error {
+		if err := h.sendSCP(uri.Path, w.Mode(), w.ModTime(), b); err != nil {
+			return files.PathError("write", fixURL.String(), err)
+		}
+
+		return nil
+	})
+
+	return &scpWriter{
+		Writer:      w,
+		releaseHost: release,
+	}, nil
+}
+
+type scpWriter struct {
+	*wrapper.Writer
+
+	releaseHost func()
+}
+
+func (w *scpWriter) Close() error {
+	defer w.releaseHost()
+
+	return w.Writer.Close()
+}
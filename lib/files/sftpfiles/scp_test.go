@@ -0,0 +1,219 @@
+package sftpfiles
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/puellanivis/breton/lib/files"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// fakeSCP implements just enough of the remote side of scp -f and scp -t, to test against.
+func fakeSCP(ch ssh.Channel, command string) uint32 {
+	fields := strings.SplitN(command, " -- ", 2)
+	if len(fields) != 2 {
+		return 1
+	}
+
+	filename := strings.ReplaceAll(strings.Trim(fields[1], "'"), `'\''`, "'")
+	r := bufio.NewReader(ch)
+
+	switch {
+	case strings.HasPrefix(fields[0], "scp -f"):
+		if err := readAck(r); err != nil {
+			return 1
+		}
+
+		fi, err := os.Stat(filename)
+		if err != nil {
+			fmt.Fprintf(ch, "\x01scp: %s: No such file or directory\n", filename)
+			return 1
+		}
+
+		b, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return 1
+		}
+
+		fmt.Fprintf(ch, "T%d 0 %d 0\n", fi.ModTime().Unix(), fi.ModTime().Unix())
+		if err := readAck(r); err != nil {
+			return 1
+		}
+
+		fmt.Fprintf(ch, "C%04o %d %s\n", fi.Mode().Perm(), len(b), fi.Name())
+		if err := readAck(r); err != nil {
+			return 1
+		}
+
+		ch.Write(b)
+		ch.Write([]byte{0})
+
+		if err := readAck(r); err != nil {
+			return 1
+		}
+
+		return 0
+
+	case strings.HasPrefix(fields[0], "scp -t"):
+		ch.Write([]byte{0})
+
+		var mtime time.Time
+
+		for {
+			line, err := r.ReadString('\n')
+			if err == io.EOF {
+				return 0
+			}
+			if err != nil {
+				return 1
+			}
+
+			switch line[0] {
+			case 'T':
+				secs, _ := strconv.ParseInt(strings.Fields(line[1:])[0], 10, 64)
+				mtime = time.Unix(secs, 0)
+				ch.Write([]byte{0})
+
+			case 'C':
+				header := strings.SplitN(strings.TrimSuffix(line[1:], "\n"), " ", 3)
+				mode, _ := strconv.ParseUint(header[0], 8, 32)
+				size, _ := strconv.Atoi(header[1])
+				ch.Write([]byte{0})
+
+				b := make([]byte, size)
+				if _, err := io.ReadFull(r, b); err != nil {
+					return 1
+				}
+
+				if err := readAck(r); err != nil {
+					return 1
+				}
+
+				if err := ioutil.WriteFile(filename, b, os.FileMode(mode)); err != nil {
+					return 1
+				}
+				_ = os.Chmod(filename, os.FileMode(mode))
+				_ = os.Chtimes(filename, mtime, mtime)
+
+				ch.Write([]byte{0})
+
+			default:
+				return 1
+			}
+		}
+	}
+
+	return 1
+}
+
+func TestSCP(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sftpfiles")
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := newTestServer(t)
+	s.exec = fakeSCP
+	defer s.Close()
+
+	h := s.host()
+	defer h.Close()
+
+	fs := new(filesystem)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filename := filepath.Join(dir, "it's a test")
+	uri := &url.URL{
+		Scheme: "scp",
+		Host:   s.l.Addr().String(),
+		Path:   filename,
+	}
+
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	w, err := fs.createSCP(ctx, h, uri)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if _, err := files.WithFileMode(0600)(w); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if _, err := w.Write([]byte("hello world")); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if string(b) != "hello world" {
+		t.Errorf("got %q, expected %q", b, "hello world")
+	}
+
+	if err := os.Chtimes(filename, mtime, mtime); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	r, err := fs.openSCP(ctx, h, uri)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	defer r.Close()
+
+	fi, err := r.Stat()
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if fi.Mode().Perm() != 0600 {
+		t.Errorf("got mode %v, expected %v", fi.Mode().Perm(), os.FileMode(0600))
+	}
+
+	if !fi.ModTime().Equal(mtime) {
+		t.Errorf("got mtime %v, expected %v", fi.ModTime(), mtime)
+	}
+
+	b, err = files.ReadFrom(r)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if string(b) != "hello world" {
+		t.Errorf("got %q, expected %q", b, "hello world")
+	}
+
+	missing := *uri
+	missing.Path = filepath.Join(dir, "missing")
+
+	r, err = fs.openSCP(ctx, h, &missing)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	defer r.Close()
+
+	if _, err := r.Stat(); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("got error %v, expected os.ErrNotExist", err)
+	}
+}
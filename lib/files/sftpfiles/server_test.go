@@ -0,0 +1,173 @@
+package sftpfiles
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// testServer is an in-process ssh server, which serves the sftp subsystem from the local filesystem.
+type testServer struct {
+	t *testing.T
+
+	l       net.Listener
+	hostKey ssh.Signer
+
+	mu    sync.Mutex
+	conns []net.Conn
+
+	// exec, if set, handles exec requests on a session.
+	exec func(ch ssh.Channel, command string) uint32
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	hostKey, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	s := &testServer{
+		t:       t,
+		l:       l,
+		hostKey: hostKey,
+	}
+
+	go s.serve()
+
+	return s
+}
+
+func (s *testServer) Close() {
+	s.l.Close()
+	s.dropAll()
+}
+
+// dropAll closes every connection to the server, as a server dropping its sessions would.
+func (s *testServer) dropAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+
+	s.conns = nil
+}
+
+// host returns a new Host for the test server, which authenticates with a password, and validates the host key.
+func (s *testServer) host() *Host {
+	h := NewHost(&url.URL{
+		Host: s.l.Addr().String(),
+		User: url.UserPassword("user", "pass"),
+	})
+
+	_, _ = h.SetHostKeyCallback(ssh.FixedHostKey(s.hostKey.PublicKey()), nil)
+
+	return h
+}
+
+func (s *testServer) serve() {
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(meta ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if meta.User() != "user" || string(pass) != "pass" {
+				return nil, errors.New("access denied")
+			}
+
+			return nil, nil
+		},
+	}
+	config.AddHostKey(s.hostKey)
+
+	for {
+		conn, err := s.l.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.conns = append(s.conns, conn)
+		s.mu.Unlock()
+
+		go s.handle(conn, config)
+	}
+}
+
+func (s *testServer) handle(conn net.Conn, config *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+
+	go ssh.DiscardRequests(reqs)
+
+	for newCh := range chans {
+		if newCh.ChannelType() != "session" {
+			newCh.Reject(ssh.UnknownChannelType, "unsupported channel type")
+			continue
+		}
+
+		ch, reqs, err := newCh.Accept()
+		if err != nil {
+			continue
+		}
+
+		go s.session(ch, reqs)
+	}
+}
+
+func (s *testServer) session(ch ssh.Channel, reqs <-chan *ssh.Request) {
+	defer ch.Close()
+
+	for req := range reqs {
+		var payload struct {
+			Value string
+		}
+
+		if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+			req.Reply(false, nil)
+			continue
+		}
+
+		switch {
+		case req.Type == "subsystem" && payload.Value == "sftp":
+			req.Reply(true, nil)
+
+			srv, err := sftp.NewServer(ch)
+			if err != nil {
+				return
+			}
+
+			_ = srv.Serve()
+			return
+
+		case req.Type == "exec" && s.exec != nil:
+			req.Reply(true, nil)
+
+			status := s.exec(ch, payload.Value)
+
+			_, _ = ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+			return
+		}
+
+		req.Reply(false, nil)
+	}
+}
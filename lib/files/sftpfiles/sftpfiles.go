@@ -2,16 +2,19 @@ package sftpfiles
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 	"sync"
 
 	"github.com/puellanivis/breton/lib/files"
 	"github.com/puellanivis/breton/lib/os/user"
 
 	"golang.org/x/crypto/ssh"
-	"golang.org/x/crypto/ssh/knownhosts"
 )
 
 type filesystem struct {
@@ -19,7 +22,8 @@ type filesystem struct {
 
 	agent      *Agent
 	auths      []ssh.AuthMethod
-	knownhosts ssh.HostKeyCallback
+	knownHosts []string
+	config     *Config
 
 	mu    sync.Mutex
 	hosts map[string]*Host
@@ -28,15 +32,22 @@ type filesystem struct {
 func (fs *filesystem) lazyInit() {
 	if agent, err := GetAgent(); err == nil && agent != nil {
 		fs.agent = agent
-		fs.auths = append(fs.auths, ssh.PublicKeysCallback(agent.Signers))
 	}
 
+	var configs []string
+
 	if home, err := user.CurrentHomeDir(); err == nil {
-		filename := filepath.Join(home, ".ssh", "known_hosts")
+		fs.knownHosts = append(fs.knownHosts, filepath.Join(home, ".ssh", "known_hosts"))
 
-		if cb, err := knownhosts.New(filename); err == nil {
-			fs.knownhosts = cb
-		}
+		configs = append(configs, filepath.Join(home, ".ssh", "config"))
+	}
+
+	configs = append(configs, "/etc/ssh/ssh_config")
+	fs.knownHosts = append(fs.knownHosts, "/etc/ssh/ssh_known_hosts")
+
+	// A broken configuration should not prevent connecting entirely, it is just not used.
+	if config, err := LoadConfig(configs...); err == nil {
+		fs.config = config
 	}
 }
 
@@ -48,36 +59,220 @@ func init() {
 	files.RegisterScheme(fs, "sftp", "scp")
 }
 
-func (fs *filesystem) getHost(uri *url.URL) *Host {
+// maxJumps is the maximum number of jump hosts that a connection may pass through.
+const maxJumps = 8
+
+// hostOptions are the settings for a Host given in the query of a URL.
+//
+// They are part of the key that a Host is shared by,
+// so that they only ever apply to connections made for URLs that give the same settings.
+// In particular, a connection is never shared with a URL that pins different host keys, or that trusts new host keys differently.
+type hostOptions struct {
+	identities []string
+
+	policy       *HostKeyPolicy
+	fingerprints []string
+}
+
+func getHostOptions(q url.Values) (*hostOptions, error) {
+	o := new(hostOptions)
+
+	for _, filename := range q["identity"] {
+		o.identities = append(o.identities, expandTilde(filename))
+	}
+
+	if name := q.Get("hostkey"); name != "" {
+		policy, err := ParseHostKeyPolicy(name)
+		if err != nil {
+			return nil, err
+		}
+
+		o.policy = &policy
+	}
+
+	for _, fingerprint := range q["fingerprint"] {
+		// A "+" in base64 becomes a space when unescaped from a query, unless it was escaped itself.
+		o.fingerprints = append(o.fingerprints, strings.ReplaceAll(fingerprint, " ", "+"))
+	}
+
+	return o, nil
+}
+
+// key returns the part of the key of a Host that comes from the options.
+func (o *hostOptions) key() string {
+	if o == nil {
+		return ""
+	}
+
+	var key string
+
+	for _, filename := range o.identities {
+		key += ";identity=" + filename
+	}
+
+	if o.policy != nil {
+		key += ";hostkey=" + o.policy.String()
+	}
+
+	for _, fingerprint := range o.fingerprints {
+		key += ";fingerprint=" + fingerprint
+	}
+
+	return key
+}
+
+// apply applies the options to a new Host, before it is shared.
+func (o *hostOptions) apply(h *Host) {
+	if o == nil {
+		return
+	}
+
+	for _, filename := range o.identities {
+		_ = h.AddIdentityFile(filename)
+	}
+
+	if o.policy != nil {
+		_ = h.SetHostKeyPolicy(*o.policy)
+	}
+
+	if len(o.fingerprints) > 0 {
+		_ = h.SetHostKeyFingerprints(o.fingerprints)
+	}
+}
+
+// getHost returns the Host for the given URL.
+//
+// A jump query parameter overrides any ProxyJump from the ssh config,
+// and any identity query parameters are added to the identity files of the Host.
+//
+// A hostkey query parameter of "strict" or "accept-new" sets the HostKeyPolicy of the Host,
+// and any fingerprint query parameters pin the host keys that the Host accepts.
+func (fs *filesystem) getHost(uri *url.URL) (*Host, error) {
 	fs.once.Do(fs.lazyInit)
 
-	h := NewHost(uri)
+	q := uri.Query()
+
+	opts, err := getHostOptions(q)
+	if err != nil {
+		return nil, err
+	}
 
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
-	key := h.Name()
+	return fs.lookupHost(uri, q.Get("jump"), 0, opts)
+}
+
+// lookupHost returns the Host for the given URL, reached through the given comma-separated list of jump hosts.
+// If the list of jump hosts is empty, then any ProxyJump from the ssh config is used.
+//
+// Hosts are shared, so that connections to the same host through the same jump hosts, and with the same options, are reused.
+// The options only apply to the Host of the URL itself, not to its jump hosts.
+//
+// Caller MUST hold the lock.
+func (fs *filesystem) lookupHost(uri *url.URL, jump string, depth int, opts *hostOptions) (*Host, error) {
+	var remoteUser string
+	if uri.User != nil {
+		remoteUser = uri.User.Username()
+	}
+
+	hc := fs.config.ForHost(uri.Hostname(), remoteUser)
+
+	h := NewHost(hc.resolveURL(uri))
+
+	if jump == "" {
+		jump = hc.Get("ProxyJump")
+	}
+
+	if jump != "" && !strings.EqualFold(jump, "none") {
+		if depth >= maxJumps {
+			return nil, errors.New("too many jump hosts")
+		}
+
+		hops := strings.Split(jump, ",")
+
+		last, err := parseJump(hops[len(hops)-1])
+		if err != nil {
+			return nil, err
+		}
+
+		// Like ssh(1), each hop is reached through the hops before it.
+		via, err := fs.lookupHost(last, strings.Join(hops[:len(hops)-1], ","), depth+1, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		_ = h.SetJumpHost(via)
+	}
+
+	key := hostKey(h) + opts.key()
 
 	if h := fs.hosts[key]; h != nil {
-		return h
+		return h, nil
+	}
+
+	if fs.agent != nil && !strings.EqualFold(hc.Get("IdentitiesOnly"), "yes") {
+		h.addSigners(fs.agent.Signers)
 	}
 
 	_ = h.addAuths(fs.auths...)
-	_, _ = h.SetHostKeyCallback(fs.knownhosts, nil)
+	_ = h.SetKnownHostsFiles(fs.knownHosts)
+	h.applyConfig(hc)
+	opts.apply(h)
 
 	fs.hosts[key] = h
 
-	return h
+	return h, nil
+}
+
+// hostKey returns the name of the Host, followed by the names of each of its jump hosts.
+func hostKey(h *Host) string {
+	key := h.Name()
+
+	for j := h.jump; j != nil; j = j.jump {
+		key += "," + j.Name()
+	}
+
+	return key
+}
+
+// parseJump parses a jump host of the form [user@]host[:port] or ssh://[user@]host[:port].
+func parseJump(hop string) (*url.URL, error) {
+	hop = strings.TrimSpace(hop)
+
+	if !strings.Contains(hop, "://") {
+		hop = "ssh://" + hop
+	}
+
+	uri, err := url.Parse(hop)
+	if err != nil {
+		return nil, err
+	}
+
+	if uri.Host == "" {
+		return nil, fmt.Errorf("invalid jump host: %q", hop)
+	}
+
+	return uri, nil
 }
 
 func (fs *filesystem) List(ctx context.Context, uri *url.URL) ([]os.FileInfo, error) {
-	h := fs.getHost(uri)
+	h, err := fs.getHost(uri)
+	if err != nil {
+		return nil, files.PathError("connect", uri.String(), err)
+	}
+
+	release := h.use()
+	defer release()
 
 	cl, err := h.Connect()
 	if err != nil {
 		return nil, files.PathError("connect", h.Name(), err)
 	}
 
+	done := h.limit()
+	defer done()
+
 	fi, err := cl.ReadDir(uri.Path)
 	if err != nil {
 		fixURL := *uri
@@ -89,3 +284,68 @@ func (fs *filesystem) List(ctx context.Context, uri *url.URL) ([]os.FileInfo, er
 
 	return fi, nil
 }
+
+// MkdirAll creates the directory at uri.Path, along with any missing parents.
+// The permissions are set on every directory created, but not on those that already exist.
+func (fs *filesystem) MkdirAll(ctx context.Context, uri *url.URL, perm os.FileMode) error {
+	h, err := fs.getHost(uri)
+	if err != nil {
+		return files.PathError("connect", uri.String(), err)
+	}
+
+	release := h.use()
+	defer release()
+
+	cl, err := h.Connect()
+	if err != nil {
+		return files.PathError("connect", h.Name(), err)
+	}
+
+	done := h.limit()
+	defer done()
+
+	fixURL := *uri
+	fixURL.Host = h.uri.Host
+	fixURL.User = h.uri.User
+
+	// Find the directories that are missing, from the deepest up.
+	var missing []string
+
+	for dir := path.Clean(uri.Path); ; dir = path.Dir(dir) {
+		fi, err := cl.Stat(dir)
+		if err == nil {
+			if !fi.IsDir() {
+				return files.PathError("mkdir", fixURL.String(), files.ErrNotDirectory)
+			}
+
+			break
+		}
+
+		if !os.IsNotExist(err) {
+			return files.PathError("mkdir", fixURL.String(), err)
+		}
+
+		missing = append(missing, dir)
+
+		if parent := path.Dir(dir); parent == dir {
+			break
+		}
+	}
+
+	for i := len(missing) - 1; i >= 0; i-- {
+		if err := cl.Mkdir(missing[i]); err != nil {
+			// Another client may have created it in the meantime.
+			if fi, serr := cl.Stat(missing[i]); serr != nil || !fi.IsDir() {
+				return files.PathError("mkdir", fixURL.String(), err)
+			}
+
+			continue
+		}
+
+		if err := cl.Chmod(missing[i], perm); err != nil {
+			return files.PathError("chmod", fixURL.String(), err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,571 @@
+package sftpfiles
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/puellanivis/breton/lib/os/user"
+)
+
+// maxIncludeDepth is the maximum nesting of Include directives, the same limit as OpenSSH.
+const maxIncludeDepth = 16
+
+// multiValued lists the keywords, where every value given is used, rather than only the first value obtained.
+var multiValued = map[string]bool{
+	"certificatefile": true,
+	"identityfile":    true,
+	"localforward":    true,
+	"remoteforward":   true,
+	"sendenv":         true,
+	"setenv":          true,
+}
+
+// matchContext holds what a Host or Match criterion is evaluated against.
+type matchContext struct {
+	host         string // the hostname, after any HostName substitution.
+	originalHost string // the hostname, as given in the URL.
+	user         string // the remote user.
+	localUser    string
+}
+
+type criterion func(mc *matchContext) bool
+
+type configEntry struct {
+	conds []criterion
+	key   string
+	args  []string
+}
+
+func (e *configEntry) matches(mc *matchContext) bool {
+	for _, cond := range e.conds {
+		if !cond(mc) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Config is a parsed OpenSSH client configuration, as described in ssh_config(5).
+//
+// Host and Match blocks, including Match host, originalhost, user, localuser, and all criteria, are supported.
+// Include directives are followed, with relative paths resolved against the directory of the top-level file.
+type Config struct {
+	entries []*configEntry
+}
+
+// LoadConfig reads each of the given OpenSSH client configuration files in order,
+// so that values from earlier files take precedence over later files.
+//
+// Files that do not exist are skipped without error.
+func LoadConfig(filenames ...string) (*Config, error) {
+	c := new(Config)
+
+	for _, filename := range filenames {
+		if err := c.include(filename, filepath.Dir(filename), nil, 0); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// ParseConfig parses an OpenSSH client configuration from the given io.Reader.
+//
+// Relative paths in Include directives are resolved against the given directory.
+func ParseConfig(r io.Reader, dir string) (*Config, error) {
+	c := new(Config)
+
+	if err := c.parse(r, "config", dir, nil, 0); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *Config) include(filename, dir string, conds []criterion, depth int) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return c.parse(f, filename, dir, conds, depth)
+}
+
+func (c *Config) parse(r io.Reader, name, dir string, outer []criterion, depth int) error {
+	if depth > maxIncludeDepth {
+		return fmt.Errorf("%s: too many nested includes", name)
+	}
+
+	// Until the first Host or Match, everything applies to every host.
+	conds := outer
+
+	scanner := bufio.NewScanner(r)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		key, args, err := splitConfigLine(scanner.Text())
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", name, lineno, err)
+		}
+
+		if key == "" {
+			continue
+		}
+
+		switch key {
+		case "host":
+			if len(args) < 1 {
+				return fmt.Errorf("%s:%d: Host requires at least one pattern", name, lineno)
+			}
+
+			conds = append(outer[:len(outer):len(outer)], hostCriterion(args))
+
+		case "match":
+			cond, err := matchCriterion(args)
+			if err != nil {
+				return fmt.Errorf("%s:%d: %w", name, lineno, err)
+			}
+
+			conds = append(outer[:len(outer):len(outer)], cond)
+
+		case "include":
+			for _, pattern := range args {
+				pattern = expandTilde(pattern)
+				if !filepath.IsAbs(pattern) {
+					pattern = filepath.Join(dir, pattern)
+				}
+
+				filenames, err := filepath.Glob(pattern)
+				if err != nil {
+					return fmt.Errorf("%s:%d: %w", name, lineno, err)
+				}
+
+				for _, filename := range filenames {
+					if err := c.include(filename, dir, conds, depth+1); err != nil {
+						return err
+					}
+				}
+			}
+
+		default:
+			if len(args) < 1 {
+				return fmt.Errorf("%s:%d: %s requires an argument", name, lineno, key)
+			}
+
+			c.entries = append(c.entries, &configEntry{
+				conds: conds,
+				key:   key,
+				args:  args,
+			})
+		}
+	}
+
+	return scanner.Err()
+}
+
+// splitConfigLine splits a configuration line into its lowercased keyword and its arguments.
+// The keyword and arguments may be separated by whitespace or an optional "=",
+// and arguments may be quoted with double quotes.
+func splitConfigLine(line string) (key string, args []string, err error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", nil, nil
+	}
+
+	i := strings.IndexAny(line, " \t=")
+	if i < 0 {
+		return strings.ToLower(line), nil, nil
+	}
+
+	key, line = strings.ToLower(line[:i]), strings.TrimSpace(line[i:])
+	line = strings.TrimSpace(strings.TrimPrefix(line, "="))
+
+	for line != "" {
+		var arg string
+
+		if line[0] == '"' {
+			end := strings.IndexByte(line[1:], '"')
+			if end < 0 {
+				return "", nil, errors.New("unterminated quoted string")
+			}
+
+			arg, line = line[1:end+1], line[end+2:]
+
+		} else {
+			end := strings.IndexAny(line, " \t")
+			if end < 0 {
+				end = len(line)
+			}
+
+			arg, line = line[:end], line[end:]
+		}
+
+		if strings.HasPrefix(arg, "#") {
+			// Trailing comment.
+			break
+		}
+
+		args = append(args, arg)
+		line = strings.TrimSpace(line)
+	}
+
+	return key, args, nil
+}
+
+// matchPattern matches a string against a single pattern, where "*" matches any run of characters, and "?" any single character.
+func matchPattern(pattern, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			pattern = pattern[1:]
+			if pattern == "" {
+				return true
+			}
+
+			for i := 0; i <= len(s); i++ {
+				if matchPattern(pattern, s[i:]) {
+					return true
+				}
+			}
+
+			return false
+
+		case '?':
+			if s == "" {
+				return false
+			}
+
+		default:
+			if s == "" || pattern[0] != s[0] {
+				return false
+			}
+		}
+
+		pattern, s = pattern[1:], s[1:]
+	}
+
+	return s == ""
+}
+
+// matchPatternList matches a string against a list of patterns.
+// Any pattern may be negated with a leading "!".
+// The list matches if any pattern matches, and no negated pattern matches.
+func matchPatternList(patterns []string, s string) bool {
+	s = strings.ToLower(s)
+
+	var matched bool
+
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+
+		if strings.HasPrefix(pattern, "!") {
+			if matchPattern(pattern[1:], s) {
+				return false
+			}
+			continue
+		}
+
+		if matchPattern(pattern, s) {
+			matched = true
+		}
+	}
+
+	return matched
+}
+
+func hostCriterion(patterns []string) criterion {
+	return func(mc *matchContext) bool {
+		return matchPatternList(patterns, mc.originalHost)
+	}
+}
+
+// matchCriterion parses the arguments of a Match directive into a criterion.
+//
+// Criteria that cannot be evaluated here, like exec, never match.
+func matchCriterion(args []string) (criterion, error) {
+	if len(args) < 1 {
+		return nil, errors.New("Match requires at least one criterion")
+	}
+
+	var conds []criterion
+
+	for len(args) > 0 {
+		keyword := strings.ToLower(args[0])
+		args = args[1:]
+
+		negate := strings.HasPrefix(keyword, "!")
+		keyword = strings.TrimPrefix(keyword, "!")
+
+		var cond criterion
+
+		switch keyword {
+		case "all":
+			cond = func(*matchContext) bool { return true }
+
+		case "canonical", "final":
+			// We do not do hostname canonicalization, so there is only ever the one pass.
+			isFinal := keyword == "final"
+			cond = func(*matchContext) bool { return isFinal }
+
+		case "host", "originalhost", "user", "localuser", "exec", "localnetwork", "tagged":
+			if len(args) < 1 {
+				return nil, fmt.Errorf("Match %s requires an argument", keyword)
+			}
+
+			patterns := strings.Split(args[0], ",")
+			args = args[1:]
+
+			switch keyword {
+			case "host":
+				cond = func(mc *matchContext) bool { return matchPatternList(patterns, mc.host) }
+			case "originalhost":
+				cond = func(mc *matchContext) bool { return matchPatternList(patterns, mc.originalHost) }
+			case "user":
+				cond = func(mc *matchContext) bool { return matchPatternList(patterns, mc.user) }
+			case "localuser":
+				cond = func(mc *matchContext) bool { return matchPatternList(patterns, mc.localUser) }
+			default:
+				cond = func(*matchContext) bool { return false }
+			}
+
+		default:
+			return nil, fmt.Errorf("unsupported Match criterion: %s", keyword)
+		}
+
+		if negate {
+			inner := cond
+			cond = func(mc *matchContext) bool { return !inner(mc) }
+		}
+
+		conds = append(conds, cond)
+	}
+
+	return func(mc *matchContext) bool {
+		for _, cond := range conds {
+			if !cond(mc) {
+				return false
+			}
+		}
+
+		return true
+	}, nil
+}
+
+// HostConfig is the configuration that applies to a specific host.
+type HostConfig struct {
+	originalHost string
+	localUser    string
+	settings     map[string][]string
+}
+
+// ForHost evaluates the configuration for the given host, as it would be given to ssh(1).
+// The user is the remote user given in the URL, if any.
+func (c *Config) ForHost(host, remoteUser string) *HostConfig {
+	if c == nil {
+		return nil
+	}
+
+	mc := &matchContext{
+		host:         host,
+		originalHost: host,
+		user:         remoteUser,
+	}
+
+	if u := getUser(); u != nil {
+		mc.localUser = u.Username()
+	}
+
+	settings := make(map[string][]string)
+
+	for _, e := range c.entries {
+		if !e.matches(mc) {
+			continue
+		}
+
+		if multiValued[e.key] {
+			settings[e.key] = append(settings[e.key], e.args...)
+			continue
+		}
+
+		if _, ok := settings[e.key]; ok {
+			continue
+		}
+
+		settings[e.key] = e.args
+
+		switch e.key {
+		case "hostname":
+			mc.host = expandTokens(e.args[0], map[byte]string{
+				'h': host,
+			})
+
+		case "user":
+			if mc.user == "" {
+				mc.user = e.args[0]
+			}
+		}
+	}
+
+	return &HostConfig{
+		originalHost: host,
+		localUser:    mc.localUser,
+		settings:     settings,
+	}
+}
+
+// Get returns the first value of the given keyword, or the empty string if it is not set.
+// Keywords are case-insensitive.
+func (hc *HostConfig) Get(key string) string {
+	if hc == nil {
+		return ""
+	}
+
+	values := hc.settings[strings.ToLower(key)]
+	if len(values) < 1 {
+		return ""
+	}
+
+	return values[0]
+}
+
+// GetAll returns all the values of the given keyword.
+// Keywords are case-insensitive.
+func (hc *HostConfig) GetAll(key string) []string {
+	if hc == nil {
+		return nil
+	}
+
+	return hc.settings[strings.ToLower(key)]
+}
+
+// Hostname returns the real hostname to connect to, which may be substituted by a HostName keyword.
+func (hc *HostConfig) Hostname() string {
+	if hostname := hc.Get("HostName"); hostname != "" {
+		return expandTokens(hostname, map[byte]string{
+			'h': hc.originalHost,
+		})
+	}
+
+	return hc.originalHost
+}
+
+// resolveURL returns a copy of the URL with the hostname, port and user substituted according to the HostConfig.
+// A port or user given in the URL takes precedence over the HostConfig.
+func (hc *HostConfig) resolveURL(uri *url.URL) *url.URL {
+	if hc == nil {
+		return uri
+	}
+
+	port := uri.Port()
+	if port == "" {
+		port = hc.Get("Port")
+	}
+
+	uriCopy := *uri
+
+	uriCopy.Host = hc.Hostname()
+	if port != "" {
+		uriCopy.Host = net.JoinHostPort(uriCopy.Host, port)
+	} else if strings.IndexByte(uriCopy.Host, ':') >= 0 {
+		uriCopy.Host = "[" + uriCopy.Host + "]"
+	}
+
+	if uri.User == nil {
+		if u := hc.Get("User"); u != "" {
+			uriCopy.User = url.User(u)
+		}
+	}
+
+	return &uriCopy
+}
+
+// expandPath expands any leading "~/" and %-tokens in a filename from the HostConfig, for a connection to the given URL.
+func (hc *HostConfig) expandPath(filename string, uri *url.URL) string {
+	tokens := map[byte]string{
+		'h': uri.Hostname(),
+		'n': hc.originalHost,
+		'p': uri.Port(),
+		'u': hc.localUser,
+	}
+
+	if uri.User != nil {
+		tokens['r'] = uri.User.Username()
+	}
+
+	if home, err := user.CurrentHomeDir(); err == nil {
+		tokens['d'] = home
+	}
+
+	if hostname, err := os.Hostname(); err == nil {
+		tokens['l'] = hostname
+
+		if i := strings.IndexByte(hostname, '.'); i >= 0 {
+			hostname = hostname[:i]
+		}
+		tokens['L'] = hostname
+	}
+
+	tokens['i'] = strconv.Itoa(os.Getuid())
+
+	return expandTilde(expandTokens(filename, tokens))
+}
+
+// expandTokens replaces %-tokens in s with the values given, and "%%" with a literal "%".
+// Unknown tokens are left in place.
+func expandTokens(s string, tokens map[byte]string) string {
+	if strings.IndexByte(s, '%') < 0 {
+		return s
+	}
+
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' || i+1 >= len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		i++
+
+		if s[i] == '%' {
+			b.WriteByte('%')
+			continue
+		}
+
+		if val, ok := tokens[s[i]]; ok {
+			b.WriteString(val)
+			continue
+		}
+
+		b.WriteByte('%')
+		b.WriteByte(s[i])
+	}
+
+	return b.String()
+}
+
+// expandTilde expands a leading "~/" to the home directory of the current user.
+func expandTilde(filename string) string {
+	if filename != "~" && !strings.HasPrefix(filename, "~/") {
+		return filename
+	}
+
+	home, err := user.CurrentHomeDir()
+	if err != nil {
+		return filename
+	}
+
+	return filepath.Join(home, strings.TrimPrefix(filename, "~"))
+}
@@ -0,0 +1,143 @@
+package sftpfiles
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testConfig = `
+# global settings first
+ServerAliveInterval 30
+
+Host bastion
+	HostName bastion.example.com
+	User jump
+	Port 2222
+
+Host *.internal !db.internal
+	User deploy
+	IdentityFile ~/.ssh/internal_%h
+
+Match host bastion.example.com
+	IdentityFile=/keys/bastion
+
+Host *
+	User fallback
+	IdentityFile "/keys/default key"
+	HostKeyAlgorithms ssh-ed25519,rsa-sha2-512
+
+Include extra.conf
+`
+
+func TestConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sftpfiles")
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "extra.conf"), []byte("Host extra\n\tPort 2022\n"), 0600); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	c, err := ParseConfig(strings.NewReader(testConfig), dir)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	type result struct {
+		url        string
+		identities []string
+	}
+
+	tests := []struct {
+		host, user string
+		expected   result
+	}{
+		{"bastion", "", result{
+			url:        "ssh://jump@bastion.example.com:2222",
+			identities: []string{"/keys/bastion", "/keys/default key"},
+		}},
+		{"web.internal", "", result{
+			url:        "ssh://deploy@web.internal",
+			identities: []string{"~/.ssh/internal_%h", "/keys/default key"},
+		}},
+		{"db.internal", "", result{
+			url:        "ssh://fallback@db.internal",
+			identities: []string{"/keys/default key"},
+		}},
+		{"web.internal", "root", result{
+			url:        "ssh://root@web.internal",
+			identities: []string{"~/.ssh/internal_%h", "/keys/default key"},
+		}},
+		{"extra", "", result{
+			url:        "ssh://fallback@extra:2022",
+			identities: []string{"/keys/default key"},
+		}},
+	}
+
+	for _, tt := range tests {
+		uri := &url.URL{
+			Scheme: "sftp",
+			Host:   tt.host,
+		}
+		if tt.user != "" {
+			uri.User = url.User(tt.user)
+		}
+
+		hc := c.ForHost(tt.host, tt.user)
+
+		got := result{
+			url:        hc.resolveURL(uri).String(),
+			identities: hc.GetAll("IdentityFile"),
+		}
+		got.url = strings.Replace(got.url, "sftp:", "ssh:", 1)
+
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("%s: got %+v, expected %+v", tt.host, got, tt.expected)
+		}
+
+		if v := hc.Get("serveraliveinterval"); v != "30" {
+			t.Errorf("%s: got ServerAliveInterval %q, expected %q", tt.host, v, "30")
+		}
+	}
+}
+
+func TestMatchPatternList(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		s        string
+		expected bool
+	}{
+		{[]string{"*"}, "anything", true},
+		{[]string{"host?"}, "host1", true},
+		{[]string{"host?"}, "host12", false},
+		{[]string{"*.example.com"}, "WWW.Example.COM", true},
+		{[]string{"*", "!secret"}, "secret", false},
+		{[]string{"!secret"}, "other", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchPatternList(tt.patterns, tt.s); got != tt.expected {
+			t.Errorf("%q against %q: got %v, expected %v", tt.s, tt.patterns, got, tt.expected)
+		}
+	}
+}
+
+func TestExpandTokens(t *testing.T) {
+	got := expandTokens("%d/.ssh/%h_%r%%%x", map[byte]string{
+		'd': "/home/user",
+		'h': "example.com",
+		'r': "root",
+	})
+
+	expected := "/home/user/.ssh/example.com_root%%x"
+	if got != expected {
+		t.Errorf("got %q, expected %q", got, expected)
+	}
+}
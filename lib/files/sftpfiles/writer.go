@@ -17,6 +17,8 @@ type writer struct {
 	loading <-chan struct{}
 	f       *sftp.File
 	err     error
+
+	releaseHost func()
 }
 
 func (w *writer) Name() string {
@@ -31,6 +33,9 @@ func (w *writer) Stat() (os.FileInfo, error) {
 		return nil, w.err
 	}
 
+	done := w.limit()
+	defer done()
+
 	return w.f.Stat()
 }
 
@@ -42,6 +47,9 @@ func (w *writer) Write(b []byte) (n int, err error) {
 		return 0, w.err
 	}
 
+	done := w.limit()
+	defer done()
+
 	return w.f.Write(b)
 }
 
@@ -53,6 +61,9 @@ func (w *writer) Seek(offset int64, whence int) (int64, error) {
 		return 0, w.err
 	}
 
+	done := w.limit()
+	defer done()
+
 	return w.f.Seek(offset, whence)
 }
 
@@ -67,36 +78,29 @@ func (w *writer) Close() error {
 	for range w.loading {
 	}
 
+	defer w.releaseHost()
+
 	if w.err != nil {
 		// This error is a connection error, and request-scoped.
 		// So, in the context of Close, the error is irrelevant, so we ignore it.
 		return nil
 	}
 
-	return w.f.Close()
-}
-
-type noopSync struct {
-	*sftp.File
-}
+	done := w.limit()
+	defer done()
 
-func (f noopSync) Sync() error {
-	return nil
+	return w.f.Close()
 }
 
 func (fs *filesystem) Create(ctx context.Context, uri *url.URL) (files.Writer, error) {
-	h := fs.getHost(uri)
-
-	if cl := h.GetClient(); cl != nil {
-		f, err := cl.Create(uri.Path)
-		if err != nil {
-			return nil, files.PathError("create", uri.String(), err)
-		}
-
-		return noopSync{f}, nil
+	h, err := fs.getHost(uri)
+	if err != nil {
+		return nil, files.PathError("connect", uri.String(), err)
 	}
 
-	loading := make(chan struct{})
+	if uri.Scheme == "scp" {
+		return fs.createSCP(ctx, h, uri)
+	}
 
 	fixURL := *uri
 	fixURL.Host = h.uri.Host
@@ -106,9 +110,31 @@ func (fs *filesystem) Create(ctx context.Context, uri *url.URL) (files.Writer, e
 		uri:  &fixURL,
 		Host: h,
 
-		loading: loading,
+		releaseHost: h.use(),
 	}
 
+	if cl := h.GetClient(); cl != nil {
+		done := h.limit()
+		f, err := cl.Create(uri.Path)
+		done()
+
+		if err != nil {
+			w.releaseHost()
+			return nil, files.PathError("create", uri.String(), err)
+		}
+
+		loaded := make(chan struct{})
+		close(loaded)
+
+		w.loading = loaded
+		w.f = f
+
+		return w, nil
+	}
+
+	loading := make(chan struct{})
+	w.loading = loading
+
 	go func() {
 		defer close(loading)
 
@@ -125,6 +151,9 @@ func (fs *filesystem) Create(ctx context.Context, uri *url.URL) (files.Writer, e
 			return
 		}
 
+		done := h.limit()
+		defer done()
+
 		f, err := cl.Create(uri.Path)
 		if err != nil {
 			w.err = files.PathError("create", w.Name(), err)
@@ -21,7 +21,8 @@ type datagramWriter struct {
 	buf []byte
 	off int
 
-	sock *socket
+	sock  *socket
+	stats sockStats
 }
 
 func (w *datagramWriter) IgnoreErrors(state bool) bool {
@@ -65,7 +66,6 @@ func (w *datagramWriter) SetPacketSize(size int) int {
 	}
 
 	w.sock.packetSize = len(w.buf)
-	w.sock.updateDelay(len(w.buf))
 
 	// Update filename.
 	w.Info.SetNameFromURL(w.sock.uri())
@@ -77,7 +77,19 @@ func (w *datagramWriter) SetBitrate(bitrate int) int {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
-	prev := w.sock.setBitrate(bitrate, len(w.buf))
+	prev := w.sock.setBitrate(bitrate)
+
+	// Update filename.
+	w.Info.SetNameFromURL(w.sock.uri())
+
+	return prev
+}
+
+func (w *datagramWriter) SetBurst(bits int) int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	prev := w.sock.setBurst(bits)
 
 	// Update filename.
 	w.Info.SetNameFromURL(w.sock.uri())
@@ -109,10 +121,11 @@ func (w *datagramWriter) sync() (n int, err error) {
 }
 
 func (w *datagramWriter) write(b []byte) (n int, err error) {
-	// We should have already prescaled the delay, so scale=1 here.
-	w.sock.throttle(1)
+	w.sock.throttle(len(b))
 
 	n, err = w.sock.conn.Write(b)
+	w.stats.wrote(n, len(b), err)
+
 	if n != len(b) {
 		if (w.noerrs && n > 0) || err == nil {
 			err = io.ErrShortWrite
@@ -122,6 +135,16 @@ func (w *datagramWriter) write(b []byte) (n int, err error) {
 	return n, err
 }
 
+// Stats returns a snapshot of the counters of the socket.
+func (w *datagramWriter) Stats() Stats {
+	return w.stats.snapshot()
+}
+
+// SetMetrics starts, or stops, reporting the counters of the socket as metrics labelled by its URL.
+func (w *datagramWriter) SetMetrics(state bool) bool {
+	return w.stats.setMetrics(state, w.Name())
+}
+
 func (w *datagramWriter) Close() error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
@@ -149,6 +172,8 @@ func (w *datagramWriter) Write(b []byte) (n int, err error) {
 		w.sock.throttle(len(b))
 
 		n, err = w.sock.conn.Write(b)
+		w.stats.wrote(n, len(b), err)
+
 		return n, w.err(err)
 	}
 
@@ -217,6 +242,12 @@ type datagramReader struct {
 	*wrapper.Info
 	sock *socket
 
+	stats sockStats
+	oob   []byte
+
+	// source reads each packet returned by Read. It is ReadPacket, unless a framing, such as RTP, is layered over the packets.
+	source func(b []byte) (int, error)
+
 	mu sync.Mutex
 
 	buf  []byte
@@ -274,8 +305,29 @@ func (r *datagramReader) Close() error {
 
 // ReadPacket reads a single packet from a data source.
 // It is up to the caller to ensure that the given buffer is sufficient to read a full packet.
+// As the buffer for control messages is reused, it is not safe to call concurrently with another ReadPacket or Read.
 func (r *datagramReader) ReadPacket(b []byte) (n int, err error) {
-	return r.sock.conn.Read(b)
+	n, oobn, flags, err := readMsg(r.sock.conn, b, r.oob)
+
+	if total, ok := parseDrops(r.oob[:oobn]); ok {
+		r.stats.dropped(total)
+	}
+
+	r.stats.read(n, isTruncated(flags))
+
+	r.sock.throttle(n)
+
+	return n, err
+}
+
+// Stats returns a snapshot of the counters of the socket.
+func (r *datagramReader) Stats() Stats {
+	return r.stats.snapshot()
+}
+
+// SetMetrics starts, or stops, reporting the counters of the socket as metrics labelled by its URL.
+func (r *datagramReader) SetMetrics(state bool) bool {
+	return r.stats.setMetrics(state, r.Name())
 }
 
 // Read performs reads from a datagram source into a continuous stream.
@@ -297,12 +349,12 @@ func (r *datagramReader) Read(b []byte) (n int, err error) {
 
 		if len(b) >= len(r.buf) {
 			// The read can be done directly.
-			return r.ReadPacket(b)
+			return r.source(b)
 		}
 
 		// Given buffer is too small, use internal buffer.
 		r.read = 0 // reset read start buffer.
-		r.cnt, err = r.ReadPacket(r.buf)
+		r.cnt, err = r.source(r.buf)
 	}
 
 	n = copy(b, r.buf[r.read:r.cnt])
@@ -316,6 +368,12 @@ func newDatagramReader(ctx context.Context, sock *socket) *datagramReader {
 		sock: sock,
 	}
 
+	r.source = r.ReadPacket
+
+	if size := enableDrops(sock.conn); size > 0 {
+		r.oob = make([]byte, size)
+	}
+
 	r.SetPacketSize(sock.maxPacketSize)
 
 	return r
@@ -0,0 +1,56 @@
+//go:build linux
+// +build linux
+
+package socketfiles
+
+import (
+	"encoding/binary"
+	"net"
+	"syscall"
+)
+
+// enableDrops asks the kernel to report the number of packets dropped on the socket with each packet read,
+// and returns the size of the buffer needed to receive that report.
+// It returns zero, if the connection does not support it.
+func enableDrops(conn net.Conn) int {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return 0
+	}
+
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return 0
+	}
+
+	var serr error
+	err = raw.Control(func(fd uintptr) {
+		serr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_RXQ_OVFL, 1)
+	})
+	if err != nil || serr != nil {
+		return 0
+	}
+
+	return syscall.CmsgSpace(4)
+}
+
+// parseDrops returns the total number of packets dropped on the socket, from the control messages of a read.
+func parseDrops(oob []byte) (uint32, bool) {
+	msgs, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return 0, false
+	}
+
+	for _, msg := range msgs {
+		if msg.Header.Level == syscall.SOL_SOCKET && msg.Header.Type == syscall.SO_RXQ_OVFL && len(msg.Data) >= 4 {
+			return binary.NativeEndian.Uint32(msg.Data), true
+		}
+	}
+
+	return 0, false
+}
+
+// isTruncated returns true if the flags of a read report that the packet was truncated.
+func isTruncated(flags int) bool {
+	return flags&syscall.MSG_TRUNC != 0
+}
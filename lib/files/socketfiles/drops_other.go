@@ -0,0 +1,21 @@
+//go:build !linux
+// +build !linux
+
+package socketfiles
+
+import (
+	"net"
+)
+
+// enableDrops is not supported outside of Linux.
+func enableDrops(conn net.Conn) int {
+	return 0
+}
+
+func parseDrops(oob []byte) (uint32, bool) {
+	return 0, false
+}
+
+func isTruncated(flags int) bool {
+	return false
+}
@@ -0,0 +1,316 @@
+package socketfiles
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/puellanivis/breton/lib/files"
+	"github.com/puellanivis/breton/lib/files/wrapper"
+)
+
+// URL query field keys for listeners that keep accepting connections.
+const (
+	FieldMulti       = "multi"
+	FieldBacklog     = "backlog"
+	FieldIdleTimeout = "idle_timeout"
+)
+
+const (
+	// defaultBacklog is the number of accepted connections that may wait to be read, before further connections are refused.
+	defaultBacklog = 8
+
+	// handshakeTimeout bounds how long an accepted TLS connection may take to complete its handshake.
+	handshakeTimeout = 10 * time.Second
+)
+
+// listenReader reads from each connection accepted by a listener in turn, as one continuous stream.
+//
+// When a connection ends, reading continues with the next connection accepted.
+// If a connection, or the listener, goes idle for longer than the idle timeout,
+// then the connection is dropped, or the stream ends with io.EOF.
+type listenReader struct {
+	*wrapper.Info
+
+	l    net.Listener
+	idle time.Duration
+
+	conns     chan net.Conn
+	acceptErr error
+
+	// mu serializes Reads, connMu guards conn, so that Close does not need to wait on a blocked Read.
+	mu     sync.Mutex
+	connMu sync.Mutex
+	conn   net.Conn
+	closed chan struct{}
+
+	throttler
+}
+
+func (r *listenReader) accept() {
+	// Handshakes run concurrently, so that a slow client does not hold up the connections accepted after it.
+	// So, the queue may only be closed once every handshake in progress has finished.
+	var wg sync.WaitGroup
+
+	defer func() {
+		wg.Wait()
+		close(r.conns)
+	}()
+
+	for {
+		conn, err := r.l.Accept()
+		if err != nil {
+			select {
+			case <-r.closed:
+			default:
+				r.acceptErr = files.PathError("accept", r.Name(), err)
+			}
+			return
+		}
+
+		tconn, ok := conn.(*tls.Conn)
+		if !ok {
+			r.queue(conn)
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			_ = tconn.SetDeadline(time.Now().Add(handshakeTimeout))
+
+			if err := tconn.Handshake(); err != nil {
+				tconn.Close()
+				return
+			}
+
+			_ = tconn.SetDeadline(time.Time{})
+
+			r.queue(tconn)
+		}()
+	}
+}
+
+// queue adds an accepted connection to those waiting to be read, or refuses it if the backlog is full.
+func (r *listenReader) queue(conn net.Conn) {
+	select {
+	case r.conns <- conn:
+	default:
+		// The backlog is full, so refuse the connection.
+		conn.Close()
+	}
+}
+
+// next waits for the next accepted connection, and makes it the current connection.
+func (r *listenReader) next() error {
+	var timeout <-chan time.Time
+	if r.idle > 0 {
+		timer := time.NewTimer(r.idle)
+		defer timer.Stop()
+
+		timeout = timer.C
+	}
+
+	var conn net.Conn
+
+	select {
+	case c, ok := <-r.conns:
+		if !ok {
+			if r.acceptErr != nil {
+				return r.acceptErr
+			}
+
+			return io.EOF
+		}
+
+		conn = c
+
+	case <-timeout:
+		return io.EOF
+
+	case <-r.closed:
+		return io.EOF
+	}
+
+	r.connMu.Lock()
+	defer r.connMu.Unlock()
+
+	select {
+	case <-r.closed:
+		conn.Close()
+		return io.EOF
+	default:
+	}
+
+	r.conn = conn
+	return nil
+}
+
+func (r *listenReader) current() net.Conn {
+	r.connMu.Lock()
+	defer r.connMu.Unlock()
+
+	return r.conn
+}
+
+func (r *listenReader) drop(conn net.Conn) {
+	r.connMu.Lock()
+	defer r.connMu.Unlock()
+
+	if r.conn == conn {
+		r.conn = nil
+	}
+
+	conn.Close()
+}
+
+func (r *listenReader) Read(b []byte) (n int, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for {
+		conn := r.current()
+		if conn == nil {
+			if err := r.next(); err != nil {
+				return 0, err
+			}
+
+			continue
+		}
+
+		if r.idle > 0 {
+			_ = conn.SetReadDeadline(time.Now().Add(r.idle))
+		}
+
+		n, err := conn.Read(b)
+
+		r.throttle(n)
+
+		if err != nil {
+			// The connection has ended, failed, or gone idle, so move on to the next.
+			r.drop(conn)
+
+			if n < 1 {
+				continue
+			}
+		}
+
+		return n, nil
+	}
+}
+
+// RemoteAddr returns the address of the peer of the connection currently being read, if any.
+func (r *listenReader) RemoteAddr() net.Addr {
+	conn := r.current()
+	if conn == nil {
+		return nil
+	}
+
+	return conn.RemoteAddr()
+}
+
+func (r *listenReader) Seek(offset int64, whence int) (int64, error) {
+	return 0, os.ErrInvalid
+}
+
+func (r *listenReader) Close() error {
+	// Do not attempt to acquire r.mu.
+	// Doing so will deadlock with a concurrent blocking Read().
+	r.connMu.Lock()
+	defer r.connMu.Unlock()
+
+	select {
+	case <-r.closed:
+		return nil
+	default:
+	}
+
+	close(r.closed)
+
+	err := r.l.Close()
+
+	if r.conn != nil {
+		r.conn.Close()
+		r.conn = nil
+	}
+
+	// Refuse any connections still waiting.
+	go func() {
+		for conn := range r.conns {
+			conn.Close()
+		}
+	}()
+
+	return err
+}
+
+// getListenOptions returns whether the URL asks to keep accepting connections,
+// and the backlog, and idle timeout to use if so.
+func getListenOptions(q url.Values) (multi bool, backlog int, idle time.Duration, err error) {
+	multi, err = getBool(q, FieldMulti)
+	if err != nil || !multi {
+		return false, 0, 0, err
+	}
+
+	backlog, err = getInt(q, FieldBacklog)
+	if err != nil {
+		return false, 0, 0, err
+	}
+
+	if backlog < 1 {
+		backlog = defaultBacklog
+	}
+
+	if s := q.Get(FieldIdleTimeout); s != "" {
+		idle, err = time.ParseDuration(s)
+		if err != nil {
+			return false, 0, 0, err
+		}
+	}
+
+	return true, backlog, idle, nil
+}
+
+func newListenReader(ctx context.Context, l net.Listener, backlog int, idle time.Duration, t throttler) *listenReader {
+	uri := listenerURL(l)
+
+	q := make(url.Values)
+	t.setQuery(q)
+	q.Set(FieldMulti, "1")
+	q.Set(FieldBacklog, strconv.Itoa(backlog))
+	if idle > 0 {
+		q.Set(FieldIdleTimeout, idle.String())
+	}
+	uri.RawQuery = q.Encode()
+
+	r := &listenReader{
+		Info: wrapper.NewInfo(uri, 0, time.Now()),
+
+		l:    l,
+		idle: idle,
+
+		conns:  make(chan net.Conn, backlog),
+		closed: make(chan struct{}),
+
+		throttler: t,
+	}
+
+	go r.accept()
+
+	go func() {
+		select {
+		case <-r.closed:
+		case <-ctx.Done():
+			r.Close()
+		}
+	}()
+
+	return r
+}
@@ -0,0 +1,112 @@
+package socketfiles
+
+import (
+	"context"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/puellanivis/breton/lib/files"
+)
+
+func testListenMulti(t *testing.T, h files.FileStore, uri *url.URL, expected string, msgs ...string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	r, err := h.Open(ctx, uri)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	defer r.Close()
+
+	raddr, err := url.Parse(r.Name())
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	raddr.RawQuery = ""
+
+	for _, msg := range msgs {
+		w, err := h.Create(ctx, raddr)
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+
+		if _, err := w.Write([]byte(msg)); err != nil {
+			t.Fatal("unexpected error", err)
+		}
+
+		if err := w.Close(); err != nil {
+			t.Fatal("unexpected error", err)
+		}
+
+		// Ensure that connections are accepted in order.
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	b, err := files.ReadFrom(r)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if string(b) != expected {
+		t.Errorf("got %q, expected %q", b, expected)
+	}
+}
+
+func TestTCPListenMulti(t *testing.T) {
+	uri := &url.URL{
+		Scheme:   "tcp",
+		Host:     "127.0.0.1:0",
+		RawQuery: "multi=1&idle_timeout=200ms",
+	}
+
+	testListenMulti(t, &tcpHandler{}, uri, "hello world", "hello ", "world")
+}
+
+func TestTCPListenBacklog(t *testing.T) {
+	uri := &url.URL{
+		Scheme:   "tcp",
+		Host:     "127.0.0.1:0",
+		RawQuery: "multi=1&backlog=1&idle_timeout=200ms",
+	}
+
+	testListenMulti(t, &tcpHandler{}, uri, "first", "first", "refused", "refused")
+}
+
+func TestUnixListenMulti(t *testing.T) {
+	dir, err := ioutil.TempDir("", "socketfiles")
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	defer os.RemoveAll(dir)
+
+	uri := &url.URL{
+		Scheme:   "unix",
+		Path:     filepath.Join(dir, "sock"),
+		RawQuery: "multi=1&idle_timeout=200ms",
+	}
+
+	testListenMulti(t, &unixHandler{}, uri, "hello world", "hello ", "world")
+}
+
+func TestListenOptions(t *testing.T) {
+	multi, backlog, idle, err := getListenOptions(url.Values{FieldMulti: []string{"true"}})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if !multi || backlog != defaultBacklog || idle != 0 {
+		t.Errorf("got %v, %d, %v, expected true, %d, 0s", multi, backlog, idle, defaultBacklog)
+	}
+
+	if multi, _, _, _ := getListenOptions(url.Values{FieldBacklog: []string{"4"}}); multi {
+		t.Error("expected a backlog without multi to not keep accepting")
+	}
+
+	if _, _, _, err := getListenOptions(url.Values{FieldMulti: []string{"1"}, FieldIdleTimeout: []string{"soon"}}); err == nil {
+		t.Error("expected an invalid idle timeout to error")
+	}
+}
@@ -0,0 +1,42 @@
+package socketfiles
+
+import (
+	"github.com/puellanivis/breton/lib/metrics"
+)
+
+const (
+	urlLabel       = metrics.Label("url")
+	directionLabel = metrics.Label("direction")
+)
+
+// Directions of traffic on a socket.
+const (
+	directionIn  = "in"
+	directionOut = "out"
+)
+
+type metricsPack struct {
+	packets     *metrics.CounterValue
+	bytes       *metrics.CounterValue
+	shortReads  *metrics.CounterValue
+	drops       *metrics.CounterValue
+	writeErrors *metrics.CounterValue
+}
+
+var baseMetrics = &metricsPack{
+	packets:     metrics.Counter("socketfiles_packets_total", "number of datagram packets sent or received", metrics.WithLabels(urlLabel, directionLabel)),
+	bytes:       metrics.Counter("socketfiles_bytes_total", "number of datagram bytes sent or received", metrics.WithLabels(urlLabel, directionLabel)),
+	shortReads:  metrics.Counter("socketfiles_short_reads_total", "number of datagram packets truncated because the read buffer was too small", metrics.WithLabels(urlLabel)),
+	drops:       metrics.Counter("socketfiles_drops_total", "number of datagram packets dropped by the kernel because the receive queue was full", metrics.WithLabels(urlLabel)),
+	writeErrors: metrics.Counter("socketfiles_write_errors_total", "number of datagram writes that failed, including ignored errors", metrics.WithLabels(urlLabel)),
+}
+
+func (m *metricsPack) WithLabels(labels ...metrics.Labeler) *metricsPack {
+	return &metricsPack{
+		packets:     m.packets.WithLabels(labels...),
+		bytes:       m.bytes.WithLabels(labels...),
+		shortReads:  m.shortReads.WithLabels(labels...),
+		drops:       m.drops.WithLabels(labels...),
+		writeErrors: m.writeErrors.WithLabels(labels...),
+	}
+}
@@ -0,0 +1,147 @@
+package socketfiles
+
+import (
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// getInterface returns the network interface named by the iface query field, which may be an interface name, or index.
+// If the field is not set, it returns nil, meaning the system default interface.
+func getInterface(q url.Values) (*net.Interface, error) {
+	name := q.Get(FieldInterface)
+	if name == "" {
+		return nil, nil
+	}
+
+	if index, err := strconv.Atoi(name); err == nil {
+		return net.InterfaceByIndex(index)
+	}
+
+	return net.InterfaceByName(name)
+}
+
+// getSources returns the source addresses given as a comma-separated list by the sources query field.
+func getSources(q url.Values) ([]net.IP, error) {
+	value := q.Get(FieldSources)
+	if value == "" {
+		return nil, nil
+	}
+
+	var sources []net.IP
+
+	for _, s := range strings.Split(value, ",") {
+		ip := net.ParseIP(strings.TrimSpace(s))
+		if ip == nil {
+			return nil, errInvalidIP
+		}
+
+		sources = append(sources, ip)
+	}
+
+	return sources, nil
+}
+
+func formatSources(sources []net.IP) string {
+	var s []string
+
+	for _, ip := range sources {
+		s = append(s, ip.String())
+	}
+
+	return strings.Join(s, ",")
+}
+
+// joinGroup joins the multicast group on the interface, or the default interface if nil.
+// If any sources are given, then only traffic from those sources is received, i.e. source-specific multicast.
+func joinGroup(conn *net.UDPConn, group net.IP, ifi *net.Interface, sources []net.IP) error {
+	groupAddr := &net.UDPAddr{IP: group}
+
+	if group.To4() != nil {
+		p := ipv4.NewPacketConn(conn)
+
+		if len(sources) < 1 {
+			return p.JoinGroup(ifi, groupAddr)
+		}
+
+		for _, source := range sources {
+			if err := p.JoinSourceSpecificGroup(ifi, groupAddr, &net.UDPAddr{IP: source}); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	p := ipv6.NewPacketConn(conn)
+
+	if len(sources) < 1 {
+		return p.JoinGroup(ifi, groupAddr)
+	}
+
+	for _, source := range sources {
+		if err := p.JoinSourceSpecificGroup(ifi, groupAddr, &net.UDPAddr{IP: source}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setMulticastOptions sets the outgoing interface, TTL (or hop limit), and loopback of multicast packets sent on the connection.
+// It returns the TTL actually set.
+func setMulticastOptions(conn *net.UDPConn, group net.IP, ifi *net.Interface, ttl int, noLoopback bool) (int, error) {
+	if group.To4() != nil {
+		p := ipv4.NewPacketConn(conn)
+
+		if ifi != nil {
+			if err := p.SetMulticastInterface(ifi); err != nil {
+				return 0, err
+			}
+		}
+
+		if noLoopback {
+			if err := p.SetMulticastLoopback(false); err != nil {
+				return 0, err
+			}
+		}
+
+		if ttl > 0 {
+			if err := p.SetMulticastTTL(ttl); err != nil {
+				return 0, err
+			}
+
+			ttl, _ = p.MulticastTTL()
+		}
+
+		return ttl, nil
+	}
+
+	p := ipv6.NewPacketConn(conn)
+
+	if ifi != nil {
+		if err := p.SetMulticastInterface(ifi); err != nil {
+			return 0, err
+		}
+	}
+
+	if noLoopback {
+		if err := p.SetMulticastLoopback(false); err != nil {
+			return 0, err
+		}
+	}
+
+	if ttl > 0 {
+		if err := p.SetMulticastHopLimit(ttl); err != nil {
+			return 0, err
+		}
+
+		ttl, _ = p.MulticastHopLimit()
+	}
+
+	return ttl, nil
+}
@@ -43,3 +43,61 @@ func WithPacketSize(sz int) files.Option {
 		return WithPacketSize(save), nil
 	}
 }
+
+// WithBitrate paces the reads or writes of a files.File to a maximum bitrate in bits per second.
+// A bitrate that is zero or less is unlimited.
+//
+// Requires the files.File to implement `interface{ SetBitrate(int) int }`, or else no action is taken.
+func WithBitrate(bitrate int) files.Option {
+	type bitrateSetter interface {
+		SetBitrate(int) int
+	}
+
+	return func(f files.File) (files.Option, error) {
+		var save int
+
+		if w, ok := f.(bitrateSetter); ok {
+			save = w.SetBitrate(bitrate)
+		}
+
+		return WithBitrate(save), nil
+	}
+}
+
+// WithBurst sets the number of bits that may be sent at full speed after being idle, despite the bitrate.
+//
+// Requires the files.File to implement `interface{ SetBurst(int) int }`, or else no action is taken.
+func WithBurst(bits int) files.Option {
+	type burstSetter interface {
+		SetBurst(int) int
+	}
+
+	return func(f files.File) (files.Option, error) {
+		var save int
+
+		if w, ok := f.(burstSetter); ok {
+			save = w.SetBurst(bits)
+		}
+
+		return WithBurst(save), nil
+	}
+}
+
+// WithMetrics reports the counters of a datagram socket as metrics labelled by its URL.
+//
+// Requires the files.File to implement `interface{ SetMetrics(bool) bool }`, or else no action is taken.
+func WithMetrics(state bool) files.Option {
+	type metricsSetter interface {
+		SetMetrics(bool) bool
+	}
+
+	return func(f files.File) (files.Option, error) {
+		var save bool
+
+		if w, ok := f.(metricsSetter); ok {
+			save = w.SetMetrics(state)
+		}
+
+		return WithMetrics(save), nil
+	}
+}
@@ -0,0 +1,402 @@
+package socketfiles
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"math/rand"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/puellanivis/breton/lib/files"
+)
+
+// URL query field keys for RTP.
+const (
+	FieldJitter = "jitter"
+	FieldSSRC   = "ssrc"
+)
+
+const (
+	rtpVersion    = 2
+	rtpHeaderSize = 12
+
+	// rtpPayloadMP2T is the static payload type of MPEG-2 transport streams, from RFC 3551.
+	rtpPayloadMP2T = 33
+
+	// rtpClockRate is the RTP timestamp clock rate of MPEG-2 transport streams, from RFC 2250.
+	rtpClockRate = 90000
+
+	// tsPacketSize is the size of an MPEG-TS packet,
+	// and rtpPacketSize the payload size of the conventional 7 TS packets per RTP packet.
+	tsPacketSize  = 188
+	rtpPacketSize = 7 * tsPacketSize
+
+	// defaultJitter is the number of packets that are buffered waiting for a missing packet, before it is considered lost.
+	defaultJitter = 32
+)
+
+var errInvalidRTP = errors.New("invalid rtp packet")
+
+type rtpHandler struct{}
+
+func init() {
+	files.RegisterScheme(&rtpHandler{}, "rtp")
+}
+
+// rtpAddr is the net.Addr of a UDP socket carrying RTP.
+type rtpAddr struct {
+	net.Addr
+}
+
+func (rtpAddr) Network() string {
+	return "rtp"
+}
+
+// RTPStats are the statistics of an RTP stream being read.
+type RTPStats struct {
+	// Received is the number of packets received, including duplicates and late packets.
+	Received uint64
+
+	// Lost is the number of packets never received by the time they were due.
+	Lost uint64
+
+	// Reordered is the number of packets that arrived after a packet with a later sequence number,
+	// but still in time to be put back into order.
+	Reordered uint64
+
+	// Late is the number of packets that arrived after their place in the stream had passed,
+	// because they were already counted as lost, or are duplicates of packets already read.
+	// Duplicate is the number of duplicates of packets still being held.
+	// Both are dropped.
+	Late      uint64
+	Duplicate uint64
+
+	// Invalid is the number of packets dropped for not being RTP.
+	Invalid uint64
+}
+
+// rtpConn wraps a UDP connection, framing each write into an RTP packet,
+// and stripping the RTP header from each read, in order of sequence number.
+type rtpConn struct {
+	net.Conn
+
+	wmu       sync.Mutex
+	wbuf      []byte
+	seq       uint16
+	ssrc      uint32
+	timestamp uint32
+	start     time.Time
+
+	rmu     sync.Mutex
+	rbuf    []byte
+	rsize   atomic.Int64
+	read    func(b []byte) (int, error) // reads a whole packet, including its RTP header.
+	jitter  int
+	started bool
+	next    uint16
+	highest uint16
+	pending map[uint16][]byte
+	err     error
+
+	stats RTPStats
+}
+
+func newRTPConn(conn net.Conn, ssrc uint32, jitter int) *rtpConn {
+	c := &rtpConn{
+		Conn: conn,
+
+		seq:       uint16(rand.Uint32()),
+		ssrc:      ssrc,
+		timestamp: rand.Uint32(),
+		start:     time.Now(),
+
+		jitter:  jitter,
+		pending: make(map[uint16][]byte),
+	}
+
+	c.read = conn.Read
+	c.setPacketSize(defaultMaxPacketSize)
+
+	return c
+}
+
+// setPacketSize sets the maximum payload size of the packets read.
+// The read buffer is resized before the next packet is read, as a Read in progress holds it.
+func (c *rtpConn) setPacketSize(size int) {
+	if size <= 0 {
+		size = defaultMaxPacketSize
+	}
+
+	c.rsize.Store(int64(rtpHeaderSize + size))
+}
+
+// Write sends b as the payload of a single RTP packet.
+func (c *rtpConn) Write(b []byte) (n int, err error) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	if cap(c.wbuf) < rtpHeaderSize+len(b) {
+		c.wbuf = make([]byte, rtpHeaderSize+len(b))
+	}
+	pkt := c.wbuf[:rtpHeaderSize+len(b)]
+
+	elapsed := time.Since(c.start)
+	ts := c.timestamp + uint32(elapsed/time.Second)*rtpClockRate + uint32((elapsed%time.Second)*rtpClockRate/time.Second)
+
+	pkt[0] = rtpVersion << 6
+	pkt[1] = rtpPayloadMP2T
+	binary.BigEndian.PutUint16(pkt[2:], c.seq)
+	binary.BigEndian.PutUint32(pkt[4:], ts)
+	binary.BigEndian.PutUint32(pkt[8:], c.ssrc)
+	copy(pkt[rtpHeaderSize:], b)
+
+	n, err = c.Conn.Write(pkt)
+
+	// Even a failed packet uses up a sequence number, so that the receiver sees it as lost.
+	c.seq++
+
+	n -= rtpHeaderSize
+	if n < 0 {
+		n = 0
+	}
+
+	return n, err
+}
+
+// parseRTP returns the sequence number and payload of the RTP packet.
+func parseRTP(pkt []byte) (seq uint16, payload []byte, err error) {
+	if len(pkt) < rtpHeaderSize || pkt[0]>>6 != rtpVersion {
+		return 0, nil, errInvalidRTP
+	}
+
+	seq = binary.BigEndian.Uint16(pkt[2:])
+
+	hdr := rtpHeaderSize + 4*int(pkt[0]&0x0F) // CSRC identifiers.
+
+	if pkt[0]&0x10 != 0 { // Header extension.
+		if len(pkt) < hdr+4 {
+			return 0, nil, errInvalidRTP
+		}
+
+		hdr += 4 + 4*int(binary.BigEndian.Uint16(pkt[hdr+2:]))
+	}
+
+	end := len(pkt)
+	if pkt[0]&0x20 != 0 { // Padding.
+		end -= int(pkt[end-1])
+	}
+
+	if hdr > end {
+		return 0, nil, errInvalidRTP
+	}
+
+	return seq, pkt[hdr:end], nil
+}
+
+// Read returns the payload of the next RTP packet in sequence.
+//
+// Packets that arrive out of order are held until the packets before them arrive,
+// but only until more than jitter packets are held, at which point the missing packets are counted as lost.
+func (c *rtpConn) Read(b []byte) (n int, err error) {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+
+	for {
+		if payload, ok := c.pending[c.next]; ok && c.started {
+			delete(c.pending, c.next)
+			c.next++
+
+			return copy(b, payload), nil
+		}
+
+		if len(c.pending) > 0 && (len(c.pending) > c.jitter || c.err != nil) {
+			c.skip()
+			continue
+		}
+
+		if c.err != nil {
+			err := c.err
+			c.err = nil
+
+			return 0, err
+		}
+
+		if size := int(c.rsize.Load()); len(c.rbuf) != size {
+			c.rbuf = make([]byte, size)
+		}
+
+		n, err := c.read(c.rbuf)
+		if err != nil {
+			// Deliver any packets still held before returning the error.
+			c.err = err
+			continue
+		}
+
+		c.receive(c.rbuf[:n])
+	}
+}
+
+// receive adds a packet to those pending.
+func (c *rtpConn) receive(pkt []byte) {
+	atomic.AddUint64(&c.stats.Received, 1)
+
+	seq, payload, err := parseRTP(pkt)
+	if err != nil {
+		atomic.AddUint64(&c.stats.Invalid, 1)
+		return
+	}
+
+	if !c.started {
+		c.started = true
+		c.next = seq
+		c.highest = seq
+	}
+
+	if int16(seq-c.next) < 0 {
+		atomic.AddUint64(&c.stats.Late, 1)
+		return
+	}
+
+	if _, ok := c.pending[seq]; ok {
+		atomic.AddUint64(&c.stats.Duplicate, 1)
+		return
+	}
+
+	if int16(seq-c.highest) < 0 {
+		atomic.AddUint64(&c.stats.Reordered, 1)
+	} else {
+		c.highest = seq
+	}
+
+	c.pending[seq] = append([]byte(nil), payload...)
+}
+
+// skip gives up waiting for missing packets, and advances to the earliest pending packet.
+func (c *rtpConn) skip() {
+	gap := -1
+
+	for seq := range c.pending {
+		if d := int(seq - c.next); gap < 0 || d < gap {
+			gap = d
+		}
+	}
+
+	atomic.AddUint64(&c.stats.Lost, uint64(gap))
+	c.next += uint16(gap)
+}
+
+// Stats returns a snapshot of the statistics of the stream.
+func (c *rtpConn) Stats() RTPStats {
+	return RTPStats{
+		Received:  atomic.LoadUint64(&c.stats.Received),
+		Lost:      atomic.LoadUint64(&c.stats.Lost),
+		Reordered: atomic.LoadUint64(&c.stats.Reordered),
+		Late:      atomic.LoadUint64(&c.stats.Late),
+		Duplicate: atomic.LoadUint64(&c.stats.Duplicate),
+		Invalid:   atomic.LoadUint64(&c.stats.Invalid),
+	}
+}
+
+// rtpReader is a datagramReader, that returns the payloads of RTP packets.
+type rtpReader struct {
+	*datagramReader
+	conn *rtpConn
+}
+
+// SetPacketSize sets the maximum payload size of the packets read, and returns the previous value.
+func (r *rtpReader) SetPacketSize(size int) int {
+	prev := r.datagramReader.SetPacketSize(size)
+
+	r.conn.setPacketSize(r.sock.maxPacketSize)
+
+	return prev
+}
+
+// RTPStats returns the statistics of the RTP stream, including how many packets have been lost.
+func (r *rtpReader) RTPStats() RTPStats {
+	return r.conn.Stats()
+}
+
+func (h *rtpHandler) Open(ctx context.Context, uri *url.URL) (files.Reader, error) {
+	if uri.Host == "" {
+		return nil, files.PathError("open", uri.String(), errInvalidURL)
+	}
+
+	q := uri.Query()
+
+	jitter := defaultJitter
+	if s := q.Get(FieldJitter); s != "" {
+		var err error
+
+		jitter, err = strconv.Atoi(s)
+		if err != nil || jitter < 0 {
+			return nil, files.PathError("open", uri.String(), os.ErrInvalid)
+		}
+	}
+
+	sock, err := listenUDP(uri.Host, q)
+	if err != nil {
+		return nil, files.PathError("open", uri.String(), err)
+	}
+
+	sock.addr = rtpAddr{sock.addr}
+
+	// The RTP framing is layered above the datagram reader, which reads the packets from the UDP socket itself,
+	// so that kernel drops and truncated packets are still counted in its Stats.
+	dr := newDatagramReader(ctx, sock)
+
+	conn := newRTPConn(sock.conn, 0, jitter)
+	conn.read = dr.ReadPacket
+	dr.source = conn.Read
+
+	r := &rtpReader{
+		datagramReader: dr,
+		conn:           conn,
+	}
+
+	conn.setPacketSize(sock.maxPacketSize)
+
+	return r, nil
+}
+
+func (h *rtpHandler) Create(ctx context.Context, uri *url.URL) (files.Writer, error) {
+	if uri.Host == "" {
+		return nil, files.PathError("create", uri.String(), errInvalidURL)
+	}
+
+	q := uri.Query()
+
+	if q.Get(FieldPacketSize) == "" {
+		q.Set(FieldPacketSize, strconv.Itoa(rtpPacketSize))
+	}
+
+	ssrc := rand.Uint32()
+	if s := q.Get(FieldSSRC); s != "" {
+		u, err := strconv.ParseUint(s, 0, 32)
+		if err != nil {
+			return nil, files.PathError("create", uri.String(), err)
+		}
+
+		ssrc = uint32(u)
+	}
+
+	sock, err := dialUDP(ctx, uri.Host, q)
+	if err != nil {
+		return nil, files.PathError("create", uri.String(), err)
+	}
+
+	sock.conn = newRTPConn(sock.conn, ssrc, 0)
+	sock.addr = rtpAddr{sock.addr}
+
+	return newDatagramWriter(ctx, sock), nil
+}
+
+func (h *rtpHandler) List(ctx context.Context, uri *url.URL) ([]os.FileInfo, error) {
+	return nil, files.PathError("readdir", uri.String(), os.ErrInvalid)
+}
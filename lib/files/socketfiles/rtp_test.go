@@ -0,0 +1,273 @@
+package socketfiles
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/url"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// packetConn is a net.Conn that records written packets, and reads back queued packets.
+type packetConn struct {
+	net.Conn
+
+	pkts [][]byte
+}
+
+func (c *packetConn) Write(b []byte) (int, error) {
+	c.pkts = append(c.pkts, append([]byte(nil), b...))
+	return len(b), nil
+}
+
+func (c *packetConn) Read(b []byte) (int, error) {
+	if len(c.pkts) < 1 {
+		return 0, io.EOF
+	}
+
+	pkt := c.pkts[0]
+	c.pkts = c.pkts[1:]
+
+	return copy(b, pkt), nil
+}
+
+func TestRTPName(t *testing.T) {
+	sock := &socket{
+		addr: rtpAddr{&net.UDPAddr{
+			IP:   []byte{239, 0, 0, 1},
+			Port: 5004,
+		}},
+
+		packetSize: rtpPacketSize,
+		ttl:        4,
+	}
+
+	uri := sock.uri()
+	expected := "rtp://239.0.0.1:5004?pkt_size=1316&ttl=4"
+
+	if s := uri.String(); s != expected {
+		t.Errorf("got a bad URI, was expecting, but got:\n\t%v\n\t%v", expected, s)
+	}
+}
+
+func TestParseRTP(t *testing.T) {
+	pkt := []byte{
+		0xB1, rtpPayloadMP2T, 0x12, 0x34, // V=2, P, X, CC=1
+		0, 0, 0, 0, // timestamp
+		0, 0, 0, 1, // SSRC
+		0, 0, 0, 2, // CSRC
+		0xBE, 0xDE, 0, 1, // extension header, with one word
+		0, 0, 0, 0, // extension
+		'h', 'i',
+		0, 0, 3, // padding
+	}
+
+	seq, payload, err := parseRTP(pkt)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if seq != 0x1234 {
+		t.Errorf("got sequence number %#x, expected %#x", seq, 0x1234)
+	}
+
+	if string(payload) != "hi" {
+		t.Errorf("got payload %q, expected %q", payload, "hi")
+	}
+
+	if _, _, err := parseRTP(pkt[:20]); err != errInvalidRTP {
+		t.Errorf("got error %v, expected %v", err, errInvalidRTP)
+	}
+}
+
+func TestRTPReorder(t *testing.T) {
+	sent := new(packetConn)
+	w := newRTPConn(sent, 42, 0)
+
+	for i := 0; i < 10; i++ {
+		if _, err := w.Write([]byte{byte(i)}); err != nil {
+			t.Fatal("unexpected error", err)
+		}
+	}
+
+	pkts := sent.pkts
+
+	if ssrc := pkts[0][8:12]; !bytes.Equal(ssrc, []byte{0, 0, 0, 42}) {
+		t.Errorf("got SSRC %v, expected %v", ssrc, []byte{0, 0, 0, 42})
+	}
+
+	received := &packetConn{
+		pkts: [][]byte{
+			pkts[0], pkts[2], pkts[1], // reordered
+			pkts[3],
+			pkts[5], pkts[5], pkts[6], pkts[7], // 4 is lost, and 5 is duplicated
+			pkts[4], // too late
+			pkts[8], pkts[9],
+		},
+	}
+
+	r := newRTPConn(received, 0, 2)
+
+	var got []byte
+	b := make([]byte, 16)
+
+	for {
+		n, err := r.Read(b)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+
+		got = append(got, b[:n]...)
+	}
+
+	if expected := []byte{0, 1, 2, 3, 5, 6, 7, 8, 9}; !bytes.Equal(got, expected) {
+		t.Errorf("got %v, expected %v", got, expected)
+	}
+
+	expected := RTPStats{
+		Received:  11,
+		Lost:      1,
+		Reordered: 1,
+		Late:      1,
+		Duplicate: 1,
+	}
+
+	if stats := r.Stats(); stats != expected {
+		t.Errorf("got %+v, expected %+v", stats, expected)
+	}
+}
+
+func TestRTP(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	r, err := (&rtpHandler{}).Open(ctx, &url.URL{
+		Scheme: "rtp",
+		Host:   "127.0.0.1:0",
+	})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	defer r.Close()
+
+	uri, err := url.Parse(r.Name())
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	w, err := (&rtpHandler{}).Create(ctx, uri)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	defer w.Close()
+
+	data := make([]byte, 2*rtpPacketSize)
+	for i := range data {
+		data[i] = byte(i / tsPacketSize)
+	}
+
+	if _, err := w.Write(data); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	b := make([]byte, defaultMaxPacketSize)
+
+	for i := 0; i < 2; i++ {
+		n, err := r.Read(b)
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+
+		if expected := data[i*rtpPacketSize : (i+1)*rtpPacketSize]; !bytes.Equal(b[:n], expected) {
+			t.Errorf("packet %d: got %d bytes, expected the %d bytes written", i, n, len(expected))
+		}
+	}
+
+	stats := r.(*rtpReader).RTPStats()
+	if stats.Received != 2 || stats.Lost != 0 {
+		t.Errorf("got %+v, expected 2 packets received, and none lost", stats)
+	}
+}
+
+func TestRTPPacketSize(t *testing.T) {
+	sent := new(packetConn)
+	w := newRTPConn(sent, 42, 0)
+
+	payload := make([]byte, defaultMaxPacketSize+1024)
+
+	if _, err := w.Write(payload); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	r := newRTPConn(&packetConn{pkts: sent.pkts}, 0, 0)
+	r.setPacketSize(len(payload))
+
+	b := make([]byte, len(payload))
+
+	n, err := r.Read(b)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if n != len(payload) {
+		t.Errorf("got %d bytes, expected %d", n, len(payload))
+	}
+}
+
+func TestRTPStats(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	r, err := (&rtpHandler{}).Open(ctx, &url.URL{
+		Scheme:   "rtp",
+		Host:     "127.0.0.1:0",
+		RawQuery: "max_pkt_size=100",
+	})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	defer r.Close()
+
+	uri, err := url.Parse(r.Name())
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	uri.RawQuery = ""
+
+	w, err := (&rtpHandler{}).Create(ctx, uri)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	defer w.Close()
+
+	if _, err := w.Write(make([]byte, rtpPacketSize)); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	b := make([]byte, defaultMaxPacketSize)
+
+	n, err := r.Read(b)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if n != 100 {
+		t.Errorf("got %d bytes, expected the payload to be truncated to %d", n, 100)
+	}
+
+	stats := r.(*rtpReader).Stats()
+
+	if stats.Packets != 1 || stats.Bytes != rtpHeaderSize+100 {
+		t.Errorf("got %+v, expected 1 packet, and %d bytes read", stats, rtpHeaderSize+100)
+	}
+
+	if runtime.GOOS == "linux" && stats.ShortReads != 1 {
+		t.Errorf("got %d short reads, expected 1", stats.ShortReads)
+	}
+}
@@ -1,4 +1,4 @@
-// Package socketfiles implements the "tcp:", "udp:", and "unix:" URL schemes.
+// Package socketfiles implements the "tcp:", "tls:", "udp:", "rtp:", and "unix:" URL schemes.
 package socketfiles
 
 import (
@@ -20,11 +20,14 @@ var (
 // URL query field keys.
 const (
 	FieldBufferSize    = "buffer_size"
+	FieldInterface     = "iface"
 	FieldLocalAddress  = "localaddr"
 	FieldLocalPort     = "localport"
+	FieldLoopback      = "loopback"
 	FieldMaxBitrate    = "max_bitrate"
 	FieldMaxPacketSize = "max_pkt_size"
 	FieldPacketSize    = "pkt_size"
+	FieldSources       = "sources"
 	FieldTOS           = "tos"
 	FieldTTL           = "ttl"
 )
@@ -40,6 +43,11 @@ type socket struct {
 
 	tos, ttl int
 
+	// multicast settings
+	iface      string
+	sources    []net.IP
+	noLoopback bool
+
 	throttler
 }
 
@@ -77,9 +85,7 @@ func (s *socket) uri() *url.URL {
 func (s *socket) uriQuery() url.Values {
 	q := make(url.Values)
 
-	if s.bitrate > 0 {
-		q.Set(FieldMaxBitrate, strconv.Itoa(s.bitrate))
-	}
+	s.throttler.setQuery(q)
 
 	if s.bufferSize > 0 {
 		q.Set(FieldBufferSize, strconv.Itoa(s.bufferSize))
@@ -88,7 +94,7 @@ func (s *socket) uriQuery() url.Values {
 	network := s.addr.Network()
 
 	switch network {
-	case "udp", "udp4", "udp6", "unixgram", "unixpacket":
+	case "udp", "udp4", "udp6", "rtp", "unixgram", "unixpacket":
 		if s.packetSize > 0 {
 			q.Set(FieldPacketSize, strconv.Itoa(s.packetSize))
 		}
@@ -98,7 +104,22 @@ func (s *socket) uriQuery() url.Values {
 	}
 
 	switch network {
-	case "udp", "udp4", "tcp", "tcp4":
+	case "udp", "udp4", "udp6", "rtp":
+		if s.iface != "" {
+			q.Set(FieldInterface, s.iface)
+		}
+
+		if len(s.sources) > 0 {
+			q.Set(FieldSources, formatSources(s.sources))
+		}
+
+		if s.noLoopback {
+			q.Set(FieldLoopback, "0")
+		}
+	}
+
+	switch network {
+	case "udp", "udp4", "rtp", "tcp", "tcp4", "tls":
 		if s.tos > 0 {
 			q.Set(FieldTOS, "0x"+strconv.FormatInt(int64(s.tos), 16))
 		}
@@ -143,6 +164,11 @@ func sockReader(conn net.Conn, q url.Values) (*socket, error) {
 		}
 	}
 
+	t, err := getThrottler(q)
+	if err != nil {
+		return nil, err
+	}
+
 	return &socket{
 		conn: conn,
 
@@ -150,6 +176,8 @@ func sockReader(conn net.Conn, q url.Values) (*socket, error) {
 
 		bufferSize:    bufferSize,
 		maxPacketSize: maxPacketSize,
+
+		throttler: t,
 	}, nil
 }
 
@@ -185,18 +213,15 @@ func sockWriter(conn net.Conn, showLocalAddr bool, q url.Values) (*socket, error
 		}
 	}
 
-	bitrate, err := getSize(q, FieldMaxBitrate)
+	t, err := getThrottler(q)
 	if err != nil {
 		return nil, err
 	}
 
-	var t throttler
-	if bitrate > 0 {
-		t.setBitrate(bitrate, packetSize)
-	}
-
 	var tos, ttl int
 
+	group := multicastIP(raddr)
+
 	switch raddr.Network() {
 	case "udp", "udp4", "tcp", "tcp4":
 		var p *ipv4.Conn
@@ -223,7 +248,8 @@ func sockWriter(conn net.Conn, showLocalAddr bool, q url.Values) (*socket, error
 			return nil, err
 		}
 
-		if ttl > 0 {
+		// For multicast, the TTL is set on multicast packets instead, below.
+		if ttl > 0 && group == nil {
 			if p == nil {
 				p = ipv4.NewConn(conn)
 			}
@@ -236,6 +262,39 @@ func sockWriter(conn net.Conn, showLocalAddr bool, q url.Values) (*socket, error
 		}
 	}
 
+	var iface string
+	var noLoopback bool
+
+	if group != nil {
+		ifi, err := getInterface(q)
+		if err != nil {
+			return nil, err
+		}
+
+		if ifi != nil {
+			iface = ifi.Name
+		}
+
+		if value := q.Get(FieldLoopback); value != "" {
+			loopback, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, err
+			}
+
+			noLoopback = !loopback
+		}
+
+		conn, ok := conn.(*net.UDPConn)
+		if !ok {
+			return nil, syscall.EINVAL
+		}
+
+		ttl, err = setMulticastOptions(conn, group, ifi, ttl, noLoopback)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	var laddr net.Addr
 	if showLocalAddr {
 		laddr = conn.LocalAddr()
@@ -253,10 +312,23 @@ func sockWriter(conn net.Conn, showLocalAddr bool, q url.Values) (*socket, error
 		tos: tos,
 		ttl: ttl,
 
+		iface:      iface,
+		noLoopback: noLoopback,
+
 		throttler: t,
 	}, nil
 }
 
+// multicastIP returns the IP of the address, if it is a UDP multicast group address, or else nil.
+func multicastIP(addr net.Addr) net.IP {
+	udpAddr, ok := addr.(*net.UDPAddr)
+	if !ok || !udpAddr.IP.IsMulticast() {
+		return nil
+	}
+
+	return udpAddr.IP
+}
+
 var scales = map[byte]int{
 	'G': 1000000000,
 	'g': 1000000000,
@@ -302,6 +374,15 @@ func getInt(q url.Values, field string) (val int, err error) {
 	return int(i), nil
 }
 
+func getBool(q url.Values, field string) (bool, error) {
+	value := q.Get(field)
+	if value == "" {
+		return false, nil
+	}
+
+	return strconv.ParseBool(value)
+}
+
 func do(ctx context.Context, fn func() error) error {
 	done := make(chan struct{})
 
@@ -0,0 +1,148 @@
+package socketfiles
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// Stats are the counters of a datagram socket.
+type Stats struct {
+	// Packets and Bytes are the number of packets and bytes successfully read or written.
+	Packets uint64
+	Bytes   uint64
+
+	// ShortReads is the number of packets read that were truncated, because the read buffer was too small.
+	ShortReads uint64
+
+	// Drops is the number of packets dropped by the kernel, because the receive queue was full.
+	// It is only available on Linux, through SO_RXQ_OVFL.
+	Drops uint64
+
+	// WriteErrors is the number of writes that failed, or were short,
+	// including those where the error was dropped because of WithIgnoreErrors.
+	WriteErrors uint64
+}
+
+// sockStats keeps the counters of a datagram socket, and optionally reports them as metrics.
+type sockStats struct {
+	packets     uint64
+	bytes       uint64
+	shortReads  uint64
+	drops       uint64
+	writeErrors uint64
+
+	mu      sync.Mutex
+	metrics *metricsPack
+}
+
+func (s *sockStats) getMetrics() *metricsPack {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.metrics
+}
+
+// setMetrics starts, or stops, reporting the counters as metrics labelled with the given URL.
+func (s *sockStats) setMetrics(state bool, uri string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev := s.metrics != nil
+
+	s.metrics = nil
+	if state {
+		s.metrics = baseMetrics.WithLabels(urlLabel.WithValue(uri))
+	}
+
+	return prev
+}
+
+func (s *sockStats) read(n int, truncated bool) {
+	m := s.getMetrics()
+
+	if n > 0 {
+		atomic.AddUint64(&s.packets, 1)
+		atomic.AddUint64(&s.bytes, uint64(n))
+
+		if m != nil {
+			m.packets.WithLabels(directionLabel.WithValue(directionIn)).Inc()
+			m.bytes.WithLabels(directionLabel.WithValue(directionIn)).Add(float64(n))
+		}
+	}
+
+	if truncated {
+		atomic.AddUint64(&s.shortReads, 1)
+
+		if m != nil {
+			m.shortReads.Inc()
+		}
+	}
+}
+
+// dropped records the total number of packets dropped as reported by the kernel.
+// The total only ever moves forward, even if reports arrive out of order from concurrent reads.
+func (s *sockStats) dropped(total uint32) {
+	for {
+		prev := atomic.LoadUint64(&s.drops)
+		if uint64(total) <= prev {
+			return
+		}
+
+		if atomic.CompareAndSwapUint64(&s.drops, prev, uint64(total)) {
+			if m := s.getMetrics(); m != nil {
+				m.drops.Add(float64(uint64(total) - prev))
+			}
+
+			return
+		}
+	}
+}
+
+func (s *sockStats) wrote(n, expected int, err error) {
+	m := s.getMetrics()
+
+	if err != nil || n != expected {
+		atomic.AddUint64(&s.writeErrors, 1)
+
+		if m != nil {
+			m.writeErrors.Inc()
+		}
+	}
+
+	if n > 0 {
+		atomic.AddUint64(&s.packets, 1)
+		atomic.AddUint64(&s.bytes, uint64(n))
+
+		if m != nil {
+			m.packets.WithLabels(directionLabel.WithValue(directionOut)).Inc()
+			m.bytes.WithLabels(directionLabel.WithValue(directionOut)).Add(float64(n))
+		}
+	}
+}
+
+func (s *sockStats) snapshot() Stats {
+	return Stats{
+		Packets:     atomic.LoadUint64(&s.packets),
+		Bytes:       atomic.LoadUint64(&s.bytes),
+		ShortReads:  atomic.LoadUint64(&s.shortReads),
+		Drops:       atomic.LoadUint64(&s.drops),
+		WriteErrors: atomic.LoadUint64(&s.writeErrors),
+	}
+}
+
+// readMsg reads a single packet, along with its control messages, if the connection supports it.
+func readMsg(conn net.Conn, b, oob []byte) (n, oobn, flags int, err error) {
+	switch conn := conn.(type) {
+	case *net.UDPConn:
+		n, oobn, flags, _, err = conn.ReadMsgUDP(b, oob)
+		return n, oobn, flags, err
+
+	case *net.UnixConn:
+		n, oobn, flags, _, err = conn.ReadMsgUnix(b, oob)
+		return n, oobn, flags, err
+	}
+
+	n, err = conn.Read(b)
+	return n, 0, 0, err
+}
@@ -0,0 +1,114 @@
+package socketfiles
+
+import (
+	"context"
+	"net/url"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestDatagramStats(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	r, err := (&udpHandler{}).Open(ctx, &url.URL{
+		Scheme:   "udp",
+		Host:     "127.0.0.1:0",
+		RawQuery: "buffer_size=1024",
+	})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	defer r.Close()
+
+	dr := r.(*datagramReader)
+	_ = dr.SetMetrics(true)
+
+	uri, err := url.Parse(r.Name())
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	uri.RawQuery = ""
+
+	w, err := (&udpHandler{}).Create(ctx, uri)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	defer w.Close()
+
+	dw := w.(*datagramWriter)
+	_ = dw.SetMetrics(true)
+	_ = dw.IgnoreErrors(true)
+
+	// Overflow the tiny receive buffer.
+	for i := 0; i < 64; i++ {
+		if _, err := w.Write(make([]byte, 100)); err != nil {
+			t.Fatal("unexpected error", err)
+		}
+	}
+
+	if stats := dw.Stats(); stats.Packets != 64 || stats.Bytes != 6400 {
+		t.Errorf("got %+v, expected 64 packets, and 6400 bytes written", stats)
+	}
+
+	b := make([]byte, 50)
+
+	n, err := dr.ReadPacket(b)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if n != len(b) {
+		t.Errorf("got %d bytes, expected %d", n, len(b))
+	}
+
+	stats := dr.Stats()
+
+	if stats.Packets != 1 || stats.Bytes != 50 {
+		t.Errorf("got %+v, expected 1 packet, and 50 bytes read", stats)
+	}
+
+	if runtime.GOOS == "linux" {
+		if stats.ShortReads != 1 {
+			t.Errorf("got %d short reads, expected 1", stats.ShortReads)
+		}
+
+		// The kernel reports drops with the packets queued after them,
+		// so drain the queue, and then send one more packet.
+		_ = dr.sock.conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+		for {
+			if _, err := dr.ReadPacket(make([]byte, 200)); err != nil {
+				break
+			}
+		}
+		_ = dr.sock.conn.SetReadDeadline(time.Time{})
+
+		if _, err := w.Write([]byte("hello")); err != nil {
+			t.Fatal("unexpected error", err)
+		}
+
+		if _, err := dr.ReadPacket(make([]byte, 200)); err != nil {
+			t.Fatal("unexpected error", err)
+		}
+
+		if stats := dr.Stats(); stats.Drops < 1 {
+			t.Errorf("got %d drops, expected some", stats.Drops)
+		}
+	}
+
+	// With the reader gone, writes fail, but the errors are ignored.
+	r.Close()
+
+	for i := 0; i < 4; i++ {
+		if _, err := w.Write([]byte("hello")); err != nil {
+			t.Fatal("unexpected error", err)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if stats := dw.Stats(); stats.WriteErrors < 1 {
+		t.Errorf("got %+v, expected some write errors", stats)
+	}
+}
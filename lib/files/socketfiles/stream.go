@@ -2,6 +2,7 @@ package socketfiles
 
 import (
 	"context"
+	"crypto/tls"
 	"net"
 	"net/url"
 	"os"
@@ -25,7 +26,19 @@ func (w *streamWriter) SetBitrate(bitrate int) int {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
-	prev := w.sock.setBitrate(bitrate, 1)
+	prev := w.sock.setBitrate(bitrate)
+
+	// Update filename.
+	w.Info.SetNameFromURL(w.sock.uri())
+
+	return prev
+}
+
+func (w *streamWriter) SetBurst(bits int) int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	prev := w.sock.setBurst(bits)
 
 	// Update filename.
 	w.Info.SetNameFromURL(w.sock.uri())
@@ -89,6 +102,8 @@ type streamReader struct {
 
 	err  error
 	conn net.Conn
+
+	throttler
 }
 
 func (r *streamReader) Read(b []byte) (n int, err error) {
@@ -99,7 +114,11 @@ func (r *streamReader) Read(b []byte) (n int, err error) {
 		return 0, r.err
 	}
 
-	return r.conn.Read(b)
+	n, err = r.conn.Read(b)
+
+	r.throttle(n)
+
+	return n, err
 }
 
 func (r *streamReader) Seek(offset int64, whence int) (int64, error) {
@@ -120,7 +139,8 @@ func (r *streamReader) Close() error {
 	return r.conn.Close()
 }
 
-func newStreamReader(ctx context.Context, l net.Listener) (*streamReader, error) {
+// listenerURL returns the URL of the address that the listener is listening on.
+func listenerURL(l net.Listener) *url.URL {
 	// Maybe we asked for an arbitrary port,
 	// so, refresh our address to the one we’re actually listening on.
 	laddr := l.Addr()
@@ -131,28 +151,44 @@ func newStreamReader(ctx context.Context, l net.Listener) (*streamReader, error)
 		host, path = "", host
 	}
 
-	uri := &url.URL{
+	return &url.URL{
 		Scheme: laddr.Network(),
 		Host:   host,
 		Path:   path,
 	}
+}
+
+func newStreamReader(ctx context.Context, l net.Listener, t throttler) (*streamReader, error) {
+	uri := listenerURL(l)
+
+	q := make(url.Values)
+	t.setQuery(q)
+	uri.RawQuery = q.Encode()
 
 	loading := make(chan struct{})
 	r := &streamReader{
 		Info: wrapper.NewInfo(uri, 0, time.Now()),
 
 		loading: loading,
+
+		throttler: t,
 	}
 
+	// A TLS client cannot finish connecting until we complete the handshake,
+	// so do not wait for the first use before accepting.
+	_, eager := l.(tlsListener)
+
 	go func() {
 		defer close(loading)
 		defer l.Close()
 
-		select {
-		case loading <- struct{}{}:
-		case <-ctx.Done():
-			r.err = files.PathError("open", uri.String(), ctx.Err())
-			return
+		if !eager {
+			select {
+			case loading <- struct{}{}:
+			case <-ctx.Done():
+				r.err = files.PathError("open", uri.String(), ctx.Err())
+				return
+			}
 		}
 
 		var conn net.Conn
@@ -169,6 +205,15 @@ func newStreamReader(ctx context.Context, l net.Listener) (*streamReader, error)
 			return
 		}
 
+		// Complete any TLS handshake now, rather than failing on the first Read.
+		if tconn, ok := conn.(*tls.Conn); ok {
+			if err := tconn.HandshakeContext(ctx); err != nil {
+				conn.Close()
+				r.err = files.PathError("handshake", uri.String(), err)
+				return
+			}
+		}
+
 		// TODO: make the a configurable option?
 		/* if err := conn.CloseWrite(); err != nil {
 			conn.Close()
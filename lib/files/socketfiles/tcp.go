@@ -2,6 +2,7 @@ package socketfiles
 
 import (
 	"context"
+	"crypto/tls"
 	"net"
 	"net/url"
 	"os"
@@ -12,7 +13,7 @@ import (
 type tcpHandler struct{}
 
 func init() {
-	files.RegisterScheme(&tcpHandler{}, "tcp")
+	files.RegisterScheme(&tcpHandler{}, "tcp", "tls")
 }
 
 func (h *tcpHandler) Open(ctx context.Context, uri *url.URL) (files.Reader, error) {
@@ -25,12 +26,44 @@ func (h *tcpHandler) Open(ctx context.Context, uri *url.URL) (files.Reader, erro
 		return nil, files.PathError("open", uri.String(), err)
 	}
 
-	l, err := net.ListenTCP("tcp", laddr)
+	secure, err := useTLS(uri)
 	if err != nil {
 		return nil, files.PathError("open", uri.String(), err)
 	}
 
-	return newStreamReader(ctx, l)
+	var conf *tls.Config
+	if secure {
+		conf, err = tlsConfig(ctx, uri, true)
+		if err != nil {
+			return nil, files.PathError("open", uri.String(), err)
+		}
+	}
+
+	multi, backlog, idle, err := getListenOptions(uri.Query())
+	if err != nil {
+		return nil, files.PathError("open", uri.String(), err)
+	}
+
+	t, err := getThrottler(uri.Query())
+	if err != nil {
+		return nil, files.PathError("open", uri.String(), err)
+	}
+
+	tl, err := net.ListenTCP("tcp", laddr)
+	if err != nil {
+		return nil, files.PathError("open", uri.String(), err)
+	}
+
+	var l net.Listener = tl
+	if conf != nil {
+		l = tlsListener{tls.NewListener(tl, conf)}
+	}
+
+	if multi {
+		return newListenReader(ctx, l, backlog, idle, t), nil
+	}
+
+	return newStreamReader(ctx, l, t)
 }
 
 func (h *tcpHandler) Create(ctx context.Context, uri *url.URL) (files.Writer, error) {
@@ -45,6 +78,19 @@ func (h *tcpHandler) Create(ctx context.Context, uri *url.URL) (files.Writer, er
 
 	q := uri.Query()
 
+	secure, err := useTLS(uri)
+	if err != nil {
+		return nil, files.PathError("create", uri.String(), err)
+	}
+
+	var conf *tls.Config
+	if secure {
+		conf, err = tlsConfig(ctx, uri, false)
+		if err != nil {
+			return nil, files.PathError("create", uri.String(), err)
+		}
+	}
+
 	var laddr *net.TCPAddr
 
 	host := q.Get(FieldLocalAddress)
@@ -75,6 +121,20 @@ func (h *tcpHandler) Create(ctx context.Context, uri *url.URL) (files.Writer, er
 		return nil, files.PathError("create", uri.String(), err)
 	}
 
+	if conf != nil {
+		// Socket options have been set on the underlying TCP connection,
+		// so from here on, we only need the TLS connection.
+		tconn := tls.Client(conn, conf)
+
+		if err := tconn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, files.PathError("handshake", uri.String(), err)
+		}
+
+		sock.conn = tconn
+		sock.addr = tlsAddr{sock.addr}
+	}
+
 	return newStreamWriter(ctx, sock), nil
 }
 
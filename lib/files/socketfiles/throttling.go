@@ -1,72 +1,117 @@
 package socketfiles
 
 import (
+	"context"
+	"net/url"
+	"strconv"
 	"time"
+
+	"github.com/puellanivis/breton/lib/io/throttle"
+)
+
+// URL query field keys for throttling.
+const (
+	FieldBurstBits = "burst_bits"
+	FieldCatchUp   = "catch_up"
 )
 
+// throttler paces reads or writes to a maximum bitrate with a token bucket.
 type throttler struct {
 	bitrate int
+	burst   int
+	catchUp time.Duration
 
-	delay time.Duration
-	next  *time.Timer
+	limiter *throttle.Limiter
 }
 
-func (t *throttler) drain() {
-	if t.next == nil {
-		return
+// getThrottler returns a throttler from the max_bitrate, burst_bits and catch_up query fields.
+//
+// The catch_up field is a duration, or "full" to always catch up to the bitrate.
+func getThrottler(q url.Values) (throttler, error) {
+	var t throttler
+
+	burst, err := getSize(q, FieldBurstBits)
+	if err != nil {
+		return t, err
+	}
+
+	t.burst = burst
+
+	switch s := q.Get(FieldCatchUp); s {
+	case "":
+	case "full":
+		t.catchUp = -1
+	default:
+		t.catchUp, err = time.ParseDuration(s)
+		if err != nil {
+			return t, err
+		}
 	}
 
-	if !t.next.Stop() {
-		<-t.next.C
+	bitrate, err := getSize(q, FieldMaxBitrate)
+	if err != nil {
+		return t, err
 	}
+
+	t.setBitrate(bitrate)
+
+	return t, nil
 }
 
-func (t *throttler) updateDelay(prescale int) {
+// setQuery sets the query fields that describe the throttler.
+func (t *throttler) setQuery(q url.Values) {
 	if t.bitrate <= 0 {
-		t.delay = 0
-		t.drain()
-		t.next = nil
 		return
 	}
 
-	if t.next != nil {
-		t.drain()
-		t.next.Reset(0)
-	} else {
-		t.next = time.NewTimer(0)
-	}
-
-	// delay = nanoseconds per byte
-	t.delay = (8 * time.Second) / time.Duration(t.bitrate)
+	q.Set(FieldMaxBitrate, strconv.Itoa(t.bitrate))
 
-	// recalculate to the actual expected maximum bitrate
-	t.bitrate = int(8 * time.Second / t.delay)
+	if t.burst > 0 {
+		q.Set(FieldBurstBits, strconv.Itoa(t.burst))
+	}
 
-	if prescale > 1 {
-		t.delay *= time.Duration(prescale)
+	switch {
+	case t.catchUp < 0:
+		q.Set(FieldCatchUp, "full")
+	case t.catchUp > 0:
+		q.Set(FieldCatchUp, t.catchUp.String())
 	}
 }
 
-func (t *throttler) throttle(scale int) {
-	if t.next == nil {
+// throttle blocks until n bytes may be sent.
+// For reads, it is called after n bytes have been received, and so throttles the next read.
+func (t *throttler) throttle(n int) {
+	if t.limiter == nil {
 		return
 	}
 
-	<-t.next.C
+	_ = t.limiter.Wait(context.Background(), n)
+}
 
-	if scale > 1 {
-		t.next.Reset(time.Duration(scale) * t.delay)
-		return
+func (t *throttler) setBitrate(bitrate int) int {
+	prev := t.bitrate
+
+	t.bitrate = bitrate
+
+	switch {
+	case t.limiter != nil:
+		t.limiter.SetBitrate(bitrate)
+
+	case bitrate > 0:
+		t.limiter = throttle.New(bitrate, throttle.WithBurst(t.burst), throttle.WithCatchUp(t.catchUp))
 	}
 
-	t.next.Reset(t.delay)
+	return prev
 }
 
-func (t *throttler) setBitrate(bitrate, prescale int) int {
-	prev := t.bitrate
+func (t *throttler) setBurst(bits int) int {
+	prev := t.burst
 
-	t.bitrate = bitrate
-	t.updateDelay(prescale)
+	t.burst = bits
+
+	if t.limiter != nil {
+		throttle.WithBurst(bits)(t.limiter)
+	}
 
 	return prev
 }
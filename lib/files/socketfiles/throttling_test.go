@@ -0,0 +1,43 @@
+package socketfiles
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestThrottlerQuery(t *testing.T) {
+	q, err := url.ParseQuery("max_bitrate=2M&burst_bits=64k&catch_up=full")
+	if err != nil {
+		t.Fatal("unexpected error parsing constant query")
+	}
+
+	th, err := getThrottler(q)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if th.limiter == nil {
+		t.Fatal("expected a limiter to be set")
+	}
+
+	out := make(url.Values)
+	th.setQuery(out)
+
+	expected := "burst_bits=64000&catch_up=full&max_bitrate=2000000"
+	if s := out.Encode(); s != expected {
+		t.Errorf("got %q, expected %q", s, expected)
+	}
+
+	if _, err := getThrottler(url.Values{FieldCatchUp: []string{"later"}}); err == nil {
+		t.Error("expected an invalid catch_up to error")
+	}
+
+	th, err = getThrottler(make(url.Values))
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if th.limiter != nil {
+		t.Error("expected no limiter without a max_bitrate")
+	}
+}
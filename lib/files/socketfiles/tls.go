@@ -0,0 +1,129 @@
+package socketfiles
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/url"
+
+	"github.com/puellanivis/breton/lib/files"
+)
+
+// URL query field keys for TLS.
+const (
+	FieldTLS        = "tls"
+	FieldCAFile     = "ca_file"
+	FieldCertFile   = "cert_file"
+	FieldKeyFile    = "key_file"
+	FieldServerName = "server_name"
+	FieldInsecure   = "insecure"
+)
+
+// tlsAddr is a net.Addr of a TCP connection wrapped in TLS.
+type tlsAddr struct {
+	net.Addr
+}
+
+func (tlsAddr) Network() string {
+	return "tls"
+}
+
+// tlsListener is a net.Listener that accepts TLS connections,
+// and reports its address as a tlsAddr.
+type tlsListener struct {
+	net.Listener
+}
+
+func (l tlsListener) Addr() net.Addr {
+	return tlsAddr{l.Listener.Addr()}
+}
+
+// useTLS returns true if the URL has the tls scheme, or sets the tls query field.
+func useTLS(uri *url.URL) (bool, error) {
+	if uri.Scheme == "tls" {
+		return true, nil
+	}
+
+	return getBool(uri.Query(), FieldTLS)
+}
+
+// tlsConfig builds a tls.Config from the query fields of the URL.
+// Certificate and key files are read with files.Read, and so may be any URL supported by files.
+//
+// A server requires a certificate and key, and if it is given a CA file, then it requires and verifies client certificates.
+// A client verifies the server certificate against the CA file, or the system roots if none is given,
+// and presents a client certificate if it is given a certificate and key.
+func tlsConfig(ctx context.Context, uri *url.URL, server bool) (*tls.Config, error) {
+	q := uri.Query()
+
+	conf := new(tls.Config)
+
+	certFile, keyFile := q.Get(FieldCertFile), q.Get(FieldKeyFile)
+	if keyFile == "" {
+		// Allow the key and certificate to be in the same file.
+		keyFile = certFile
+	}
+
+	if certFile != "" {
+		certPEM, err := files.Read(ctx, certFile)
+		if err != nil {
+			return nil, err
+		}
+
+		keyPEM, err := files.Read(ctx, keyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return nil, err
+		}
+
+		conf.Certificates = []tls.Certificate{cert}
+	}
+
+	var pool *x509.CertPool
+
+	if caFile := q.Get(FieldCAFile); caFile != "" {
+		caPEM, err := files.Read(ctx, caFile)
+		if err != nil {
+			return nil, err
+		}
+
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, errors.New("no certificates found in CA file")
+		}
+	}
+
+	if server {
+		if len(conf.Certificates) < 1 {
+			return nil, errors.New("tls server requires a certificate")
+		}
+
+		if pool != nil {
+			conf.ClientCAs = pool
+			conf.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+
+		return conf, nil
+	}
+
+	insecure, err := getBool(q, FieldInsecure)
+	if err != nil {
+		return nil, err
+	}
+
+	conf.RootCAs = pool
+	conf.InsecureSkipVerify = insecure
+
+	conf.ServerName = q.Get(FieldServerName)
+	if conf.ServerName == "" {
+		conf.ServerName = uri.Hostname()
+	}
+
+	return conf, nil
+}
@@ -0,0 +1,303 @@
+package socketfiles
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/puellanivis/breton/lib/files"
+)
+
+// writeTestCert writes a self-signed certificate for 127.0.0.1 and its key into the directory.
+func writeTestCert(t *testing.T, dir string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)},
+		DNSNames:    []string{"localhost"},
+
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	return certFile, keyFile
+}
+
+func TestTLSName(t *testing.T) {
+	sock := &socket{
+		addr: tlsAddr{&net.TCPAddr{
+			IP:   []byte{127, 0, 0, 2},
+			Port: 443,
+		}},
+
+		tos: 0x80,
+	}
+
+	uri := sock.uri()
+	expected := "tls://127.0.0.2:443?tos=0x80"
+
+	if s := uri.String(); s != expected {
+		t.Errorf("got a bad URI, was expecting, but got:\n\t%v\n\t%v", expected, s)
+	}
+}
+
+func TestTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "socketfiles")
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	defer os.RemoveAll(dir)
+
+	certFile, keyFile := writeTestCert(t, dir)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	q := make(url.Values)
+	q.Set(FieldCertFile, certFile)
+	q.Set(FieldKeyFile, keyFile)
+
+	// Listen on tcp: with tls=1, to ensure that it is the same as the tls: scheme.
+	r, err := (&tcpHandler{}).Open(ctx, &url.URL{
+		Scheme:   "tcp",
+		Host:     "127.0.0.1:0",
+		RawQuery: q.Encode() + "&tls=1",
+	})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	defer r.Close()
+
+	uri, err := url.Parse(r.Name())
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if uri.Scheme != "tls" {
+		t.Errorf("got scheme %q, expected %q", uri.Scheme, "tls")
+	}
+
+	q = make(url.Values)
+	q.Set(FieldCAFile, certFile)
+	uri.RawQuery = q.Encode()
+
+	w, err := (&tcpHandler{}).Create(ctx, uri)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if _, err := w.Write([]byte("hello world")); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	b, err := files.ReadFrom(r)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if string(b) != "hello world" {
+		t.Errorf("got %q, expected %q", b, "hello world")
+	}
+}
+
+func TestTLSVerify(t *testing.T) {
+	dir, err := ioutil.TempDir("", "socketfiles")
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	defer os.RemoveAll(dir)
+
+	certFile, keyFile := writeTestCert(t, dir)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	listen := func(q url.Values) (files.Reader, *url.URL) {
+		q.Set(FieldCertFile, certFile)
+		q.Set(FieldKeyFile, keyFile)
+
+		r, err := (&tcpHandler{}).Open(ctx, &url.URL{
+			Scheme:   "tls",
+			Host:     "127.0.0.1:0",
+			RawQuery: q.Encode(),
+		})
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+
+		uri, err := url.Parse(r.Name())
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+
+		return r, uri
+	}
+
+	// Without the CA file, the self-signed certificate is not trusted.
+	r, uri := listen(make(url.Values))
+	defer r.Close()
+
+	if w, err := (&tcpHandler{}).Create(ctx, uri); err == nil {
+		w.Close()
+		t.Fatal("expected Create to fail to verify the server, it did not")
+	}
+
+	// Unless verification is skipped.
+	r, uri = listen(make(url.Values))
+	defer r.Close()
+
+	uri.RawQuery = FieldInsecure + "=1"
+
+	w, err := (&tcpHandler{}).Create(ctx, uri)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	w.Close()
+
+	// A server with a CA file requires a client certificate.
+	r, uri = listen(url.Values{FieldCAFile: []string{certFile}})
+	defer r.Close()
+
+	q := make(url.Values)
+	q.Set(FieldCAFile, certFile)
+	q.Set(FieldServerName, "localhost")
+	q.Set(FieldCertFile, certFile)
+	q.Set(FieldKeyFile, keyFile)
+	uri.RawQuery = q.Encode()
+
+	w, err = (&tcpHandler{}).Create(ctx, uri)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if _, err := w.Write([]byte("mutual")); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	w.Close()
+
+	b, err := files.ReadFrom(r)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if string(b) != "mutual" {
+		t.Errorf("got %q, expected %q", b, "mutual")
+	}
+}
+
+func TestTLSListenSlowHandshake(t *testing.T) {
+	dir, err := ioutil.TempDir("", "socketfiles")
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	defer os.RemoveAll(dir)
+
+	certFile, keyFile := writeTestCert(t, dir)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	q := make(url.Values)
+	q.Set(FieldCertFile, certFile)
+	q.Set(FieldKeyFile, keyFile)
+	q.Set(FieldMulti, "1")
+
+	r, err := (&tcpHandler{}).Open(ctx, &url.URL{
+		Scheme:   "tls",
+		Host:     "127.0.0.1:0",
+		RawQuery: q.Encode(),
+	})
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	defer r.Close()
+
+	uri, err := url.Parse(r.Name())
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	// A client that connects, but never starts its handshake.
+	idle, err := net.Dial("tcp", uri.Host)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	defer idle.Close()
+
+	time.Sleep(50 * time.Millisecond)
+
+	uri.RawQuery = FieldInsecure + "=1"
+
+	w, err := (&tcpHandler{}).Create(ctx, uri)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if _, err := w.Write([]byte("hello world")); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	w.Close()
+
+	start := time.Now()
+
+	b := make([]byte, len("hello world"))
+	if _, err := io.ReadFull(r, b); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if string(b) != "hello world" {
+		t.Errorf("got %q, expected %q", b, "hello world")
+	}
+
+	if d := time.Since(start); d > handshakeTimeout/2 {
+		t.Errorf("reading took %v, expected not to wait on the idle client", d)
+	}
+}
@@ -20,27 +20,61 @@ func (h *udpHandler) Open(ctx context.Context, uri *url.URL) (files.Reader, erro
 		return nil, files.PathError("open", uri.String(), errInvalidURL)
 	}
 
-	laddr, err := net.ResolveUDPAddr("udp", uri.Host)
+	sock, err := listenUDP(uri.Host, uri.Query())
 	if err != nil {
 		return nil, files.PathError("open", uri.String(), err)
 	}
 
+	return newDatagramReader(ctx, sock), nil
+}
+
+// listenUDP listens on the given address, and joins its multicast group, if it is one.
+func listenUDP(address string, q url.Values) (*socket, error) {
+	laddr, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return nil, err
+	}
+
 	conn, err := net.ListenUDP("udp", laddr)
 	if err != nil {
-		return nil, files.PathError("open", uri.String(), err)
+		return nil, err
 	}
 
 	// Maybe we asked for an arbitrary port,
 	// so, refresh our address to the one we’re actually listening on.
 	laddr = conn.LocalAddr().(*net.UDPAddr)
 
-	sock, err := sockReader(conn, uri.Query())
+	sock, err := sockReader(conn, q)
 	if err != nil {
 		conn.Close()
-		return nil, files.PathError("open", uri.String(), err)
+		return nil, err
 	}
 
-	return newDatagramReader(ctx, sock), nil
+	if group := multicastIP(laddr); group != nil {
+		ifi, err := getInterface(q)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+
+		sources, err := getSources(q)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+
+		if err := joinGroup(conn, group, ifi, sources); err != nil {
+			conn.Close()
+			return nil, err
+		}
+
+		if ifi != nil {
+			sock.iface = ifi.Name
+		}
+		sock.sources = sources
+	}
+
+	return sock, nil
 }
 
 func (h *udpHandler) Create(ctx context.Context, uri *url.URL) (files.Writer, error) {
@@ -48,12 +82,20 @@ func (h *udpHandler) Create(ctx context.Context, uri *url.URL) (files.Writer, er
 		return nil, files.PathError("create", uri.String(), errInvalidURL)
 	}
 
-	raddr, err := net.ResolveUDPAddr("udp", uri.Host)
+	sock, err := dialUDP(ctx, uri.Host, uri.Query())
 	if err != nil {
 		return nil, files.PathError("create", uri.String(), err)
 	}
 
-	q := uri.Query()
+	return newDatagramWriter(ctx, sock), nil
+}
+
+// dialUDP connects to the given address, from the local address given in the query, if any.
+func dialUDP(ctx context.Context, address string, q url.Values) (*socket, error) {
+	raddr, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return nil, err
+	}
 
 	var laddr *net.UDPAddr
 
@@ -62,7 +104,7 @@ func (h *udpHandler) Create(ctx context.Context, uri *url.URL) (files.Writer, er
 	if host != "" || port != "" {
 		laddr, err = net.ResolveUDPAddr("udp", net.JoinHostPort(host, port))
 		if err != nil {
-			return nil, files.PathError("create", uri.String(), err)
+			return nil, err
 		}
 	}
 
@@ -76,16 +118,16 @@ func (h *udpHandler) Create(ctx context.Context, uri *url.URL) (files.Writer, er
 	}
 
 	if err := do(ctx, dial); err != nil {
-		return nil, files.PathError("create", uri.String(), err)
+		return nil, err
 	}
 
 	sock, err := sockWriter(conn, laddr != nil, q)
 	if err != nil {
 		conn.Close()
-		return nil, files.PathError("create", uri.String(), err)
+		return nil, err
 	}
 
-	return newDatagramWriter(ctx, sock), nil
+	return sock, nil
 }
 
 func (h *udpHandler) List(ctx context.Context, uri *url.URL) ([]os.FileInfo, error) {
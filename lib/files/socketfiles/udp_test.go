@@ -132,3 +132,76 @@ func TestUDPBadLocalAddr(t *testing.T) {
 	}
 	cancel()
 }
+
+func TestUDPMulticastName(t *testing.T) {
+	sock := &socket{
+		addr: &net.UDPAddr{
+			IP:   net.IPv4(239, 1, 1, 1),
+			Port: 5000,
+		},
+
+		iface:      "lo",
+		sources:    []net.IP{net.IPv4(10, 0, 0, 1), net.IPv4(10, 0, 0, 2)},
+		noLoopback: true,
+		ttl:        4,
+	}
+
+	uri := sock.uri()
+	expected := "udp://239.1.1.1:5000?iface=lo&loopback=0&sources=10.0.0.1%2C10.0.0.2&ttl=4"
+
+	if s := uri.String(); s != expected {
+		t.Errorf("got a bad URI, was expecting, but got:\n\t%v\n\t%v", expected, s)
+	}
+}
+
+func TestUDPMulticast(t *testing.T) {
+	lo, err := net.InterfaceByName("lo")
+	if err != nil || lo.Flags&net.FlagMulticast == 0 {
+		t.Skip("no multicast capable loopback interface")
+	}
+
+	uri, err := url.Parse("udp://239.255.0.1:0?iface=lo&sources=127.0.0.1")
+	if err != nil {
+		t.Fatal("unexpected error parsing constant URL")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	r, err := (&udpHandler{}).Open(ctx, uri)
+	if err != nil {
+		t.Skip("cannot join multicast group:", err)
+	}
+	defer r.Close()
+
+	sock := r.(*datagramReader).sock
+	sock.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	raddr := sock.addr.(*net.UDPAddr)
+
+	uri, err = url.Parse("udp://" + raddr.String() + "?iface=lo&ttl=1&localaddr=127.0.0.1")
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	w, err := (&udpHandler{}).Create(ctx, uri)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	defer w.Close()
+
+	if _, err := w.Write([]byte("hello")); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	b := make([]byte, 1500)
+
+	n, err := r.Read(b)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if got := string(b[:n]); got != "hello" {
+		t.Errorf("got %q, expected %q", got, "hello")
+	}
+}
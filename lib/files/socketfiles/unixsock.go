@@ -44,12 +44,26 @@ func (h *unixHandler) Open(ctx context.Context, uri *url.URL) (files.Reader, err
 		return newDatagramReader(ctx, sock), nil
 
 	case "unix":
+		multi, backlog, idle, err := getListenOptions(uri.Query())
+		if err != nil {
+			return nil, files.PathError("open", uri.String(), err)
+		}
+
+		t, err := getThrottler(uri.Query())
+		if err != nil {
+			return nil, files.PathError("open", uri.String(), err)
+		}
+
 		l, err := net.ListenUnix(network, laddr)
 		if err != nil {
 			return nil, files.PathError("open", uri.String(), err)
 		}
 
-		return newStreamReader(ctx, l)
+		if multi {
+			return newListenReader(ctx, l, backlog, idle, t), nil
+		}
+
+		return newStreamReader(ctx, l, t)
 	}
 
 	return nil, files.PathError("create", uri.String(), errors.New("unknown unix socket type"))
@@ -0,0 +1,84 @@
+package files
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var errPatternHasSeparator = errors.New("pattern contains path separator")
+
+// tempName returns a name from the pattern, where the last "*" is replaced by a random string,
+// or the random string is appended, if there is no "*".
+func tempName(pattern string) (string, error) {
+	if strings.ContainsRune(pattern, '/') || strings.ContainsRune(pattern, os.PathSeparator) {
+		return "", errPatternHasSeparator
+	}
+
+	prefix, suffix := pattern, ""
+	if i := strings.LastIndexByte(pattern, '*'); i >= 0 {
+		prefix, suffix = pattern[:i], pattern[i+1:]
+	}
+
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+
+	return prefix + hex.EncodeToString(b[:]) + suffix, nil
+}
+
+// joinURL returns the URL of the given name in the directory at the given URL.
+func joinURL(dir *url.URL, name string) *url.URL {
+	uri := *dir
+
+	if uri.Opaque != "" {
+		uri.Opaque = strings.TrimSuffix(uri.Opaque, "/") + "/" + name
+		return &uri
+	}
+
+	uri.Path = path.Join(uri.Path, name)
+	uri.RawPath = ""
+
+	return &uri
+}
+
+// CreateTemp creates a new file in the directory at the given URL, with a unique name,
+// and returns a files.Writer to it, like os.CreateTemp.
+// The name is made from the pattern, by replacing the last "*" with a random string, or by appending a random string, if there is no "*".
+// The name of the file can be found with the Name method of the files.Writer.
+//
+// If the directory is the empty string, then the default local directory for temporary files is used.
+//
+// On a local filesystem, the file is created exclusively, as with os.CreateTemp.
+// On other schemes, the random string is long enough, that the name is unique with overwhelming probability.
+func CreateTemp(ctx context.Context, dir, pattern string) (Writer, error) {
+	if dir == "" || filepath.IsAbs(dir) {
+		return os.CreateTemp(dir, pattern)
+	}
+
+	if uri, err := url.Parse(dir); err == nil {
+		uri = resolveFilename(ctx, uri)
+
+		if fs, ok := getFS(uri); ok {
+			if fs == Local {
+				return os.CreateTemp(filename(uri), pattern)
+			}
+
+			name, err := tempName(pattern)
+			if err != nil {
+				return nil, PathError("createtemp", pattern, err)
+			}
+
+			return fs.Create(ctx, joinURL(uri, name))
+		}
+	}
+
+	return os.CreateTemp(dir, pattern)
+}
@@ -0,0 +1,110 @@
+package files
+
+import (
+	"context"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMkdirAllCreateTemp(t *testing.T) {
+	dir, err := ioutil.TempDir("", "files")
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+
+	sub := filepath.Join(dir, "a", "b")
+
+	if err := MkdirAll(ctx, "file://"+filepath.ToSlash(sub), 0750); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	fi, err := os.Stat(sub)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if !fi.IsDir() {
+		t.Errorf("%s is not a directory", sub)
+	}
+
+	seen := make(map[string]bool)
+
+	for i := 0; i < 4; i++ {
+		w, err := CreateTemp(ctx, sub, "scratch-*.tmp")
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+
+		name := w.Name()
+
+		if err := WriteTo(w, []byte("scratch")); err != nil {
+			t.Fatal("unexpected error", err)
+		}
+
+		base := filepath.Base(name)
+		if filepath.Dir(name) != sub || !strings.HasPrefix(base, "scratch-") || !strings.HasSuffix(base, ".tmp") {
+			t.Errorf("got temporary file %q, expected it to match %s/scratch-*.tmp", name, sub)
+		}
+
+		if seen[name] {
+			t.Errorf("got temporary file %q more than once", name)
+		}
+		seen[name] = true
+	}
+
+	if _, err := CreateTemp(ctx, sub, "bad/pattern"); err == nil {
+		t.Error("expected an error for a pattern with a path separator, got none")
+	}
+}
+
+func TestTempName(t *testing.T) {
+	tests := []struct {
+		pattern        string
+		prefix, suffix string
+	}{
+		{"scratch", "scratch", ""},
+		{"scratch-*.tmp", "scratch-", ".tmp"},
+		{"a*b*c", "a*b", "c"},
+	}
+
+	for _, tt := range tests {
+		name, err := tempName(tt.pattern)
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+
+		if len(name) != len(tt.prefix)+16+len(tt.suffix) || !strings.HasPrefix(name, tt.prefix) || !strings.HasSuffix(name, tt.suffix) {
+			t.Errorf("got %q for pattern %q, expected %q, a random string, and %q", name, tt.pattern, tt.prefix, tt.suffix)
+		}
+	}
+
+	if _, err := tempName("a/b*"); err == nil {
+		t.Error("expected an error for a pattern with a path separator, got none")
+	}
+
+	dirs := []struct {
+		dir, expect string
+	}{
+		{"s3://bucket/scratch", "s3://bucket/scratch/name"},
+		{"s3://bucket/scratch/", "s3://bucket/scratch/name"},
+		{"enc:s3://bucket/scratch", "enc:s3://bucket/scratch/name"},
+	}
+
+	for _, tt := range dirs {
+		uri, err := url.Parse(tt.dir)
+		if err != nil {
+			t.Fatal("unexpected error", err)
+		}
+
+		if got := joinURL(uri, "name").String(); got != tt.expect {
+			t.Errorf("got %q, expected %q", got, tt.expect)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+// Package xdg implements the URL schemes "xdg-config:", "xdg-cache:", "xdg-data:", "xdg-state:", and "xdg-runtime:",
+// which reference files according to the XDG Base Directory Specification.
+//
+// The base directories are taken from the XDG_* environment variables, or their defaults from the specification.
+// Relative paths in the environment variables are ignored, as the specification requires.
+//
+// Opening a file searches the user-specific base directory,
+// and then for "xdg-config:" and "xdg-data:", the directories in XDG_CONFIG_DIRS or XDG_DATA_DIRS in order.
+// Creating a file always creates it in the user-specific base directory, along with any missing directories.
+package xdg
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/puellanivis/breton/lib/files"
+	"github.com/puellanivis/breton/lib/os/user"
+)
+
+// baseDir describes a kind of base directory, and the environment variables that set it.
+type baseDir struct {
+	// env sets the user-specific base directory, or else it is the fallback under the home directory.
+	env      string
+	fallback string
+
+	// dirsEnv sets the ordered list of system base directories to search, or else defaultDirs is used.
+	dirsEnv     string
+	defaultDirs string
+}
+
+var bases = map[string]*baseDir{
+	"xdg-config": {
+		env:      "XDG_CONFIG_HOME",
+		fallback: ".config",
+
+		dirsEnv:     "XDG_CONFIG_DIRS",
+		defaultDirs: "/etc/xdg",
+	},
+	"xdg-data": {
+		env:      "XDG_DATA_HOME",
+		fallback: ".local/share",
+
+		dirsEnv:     "XDG_DATA_DIRS",
+		defaultDirs: "/usr/local/share/:/usr/share/",
+	},
+	"xdg-cache": {
+		env:      "XDG_CACHE_HOME",
+		fallback: ".cache",
+	},
+	"xdg-state": {
+		env:      "XDG_STATE_HOME",
+		fallback: ".local/state",
+	},
+	"xdg-runtime": {
+		// The specification gives no default for the runtime directory.
+		env: "XDG_RUNTIME_DIR",
+	},
+}
+
+type handler struct {
+	base *baseDir
+}
+
+func init() {
+	for scheme, base := range bases {
+		files.RegisterScheme(&handler{base: base}, scheme)
+	}
+}
+
+// errNoRuntimeDir is returned when XDG_RUNTIME_DIR is not set, as there is no default for it.
+var errNoRuntimeDir = errors.New("XDG_RUNTIME_DIR is not set")
+
+func homeDir() (string, error) {
+	if dir := os.Getenv("HOME"); filepath.IsAbs(dir) {
+		return dir, nil
+	}
+
+	return user.CurrentHomeDir()
+}
+
+// home returns the user-specific base directory.
+func (b *baseDir) home() (string, error) {
+	if dir := os.Getenv(b.env); filepath.IsAbs(dir) {
+		return dir, nil
+	}
+
+	if b.fallback == "" {
+		return "", errNoRuntimeDir
+	}
+
+	dir, err := homeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, filepath.FromSlash(b.fallback)), nil
+}
+
+// dirs returns all of the base directories to search, in order of preference.
+func (b *baseDir) dirs() ([]string, error) {
+	home, err := b.home()
+	if err != nil {
+		return nil, err
+	}
+
+	dirs := []string{home}
+
+	if b.dirsEnv == "" {
+		return dirs, nil
+	}
+
+	list := os.Getenv(b.dirsEnv)
+	if list == "" {
+		list = b.defaultDirs
+	}
+
+	for _, dir := range strings.Split(list, string(os.PathListSeparator)) {
+		if filepath.IsAbs(dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+
+	return dirs, nil
+}
+
+// relPath returns the path given in the URL, which cannot escape a base directory.
+func relPath(uri *url.URL) (string, error) {
+	if uri.Host != "" || uri.User != nil {
+		return "", os.ErrInvalid
+	}
+
+	p := uri.Path
+	if p == "" {
+		p = uri.Opaque
+	}
+
+	return filepath.FromSlash(path.Clean("/" + p)), nil
+}
+
+// Filename takes a given URL of one of the xdg schemes, and returns the absolute path of the file in the user-specific base directory.
+// This is where a file would be created, though a file of that name might instead be opened from a system base directory.
+func Filename(uri *url.URL) (string, error) {
+	base := bases[uri.Scheme]
+	if base == nil {
+		return "", os.ErrInvalid
+	}
+
+	rel, err := relPath(uri)
+	if err != nil {
+		return "", err
+	}
+
+	dir, err := base.home()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, rel), nil
+}
+
+func (h *handler) Open(ctx context.Context, uri *url.URL) (files.Reader, error) {
+	rel, err := relPath(uri)
+	if err != nil {
+		return nil, files.PathError("open", uri.String(), err)
+	}
+
+	dirs, err := h.base.dirs()
+	if err != nil {
+		return nil, files.PathError("open", uri.String(), err)
+	}
+
+	for _, dir := range dirs {
+		f, err := os.Open(filepath.Join(dir, rel))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+
+			return nil, err
+		}
+
+		return f, nil
+	}
+
+	return nil, files.PathError("open", uri.String(), os.ErrNotExist)
+}
+
+func (h *handler) Create(ctx context.Context, uri *url.URL) (files.Writer, error) {
+	filename, err := Filename(uri)
+	if err != nil {
+		return nil, files.PathError("create", uri.String(), err)
+	}
+
+	// The specification requires that missing directories are created with only the user having access.
+	if err := os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
+		return nil, err
+	}
+
+	return os.Create(filename)
+}
+
+// MkdirAll creates the directory in the user-specific base directory, along with any missing parents.
+func (h *handler) MkdirAll(ctx context.Context, uri *url.URL, perm os.FileMode) error {
+	filename, err := Filename(uri)
+	if err != nil {
+		return files.PathError("mkdir", uri.String(), err)
+	}
+
+	return os.MkdirAll(filename, perm)
+}
+
+// List lists the directory across all of the base directories.
+// Where the same name is in more than one base directory, only the first is listed, as only it can be opened.
+func (h *handler) List(ctx context.Context, uri *url.URL) ([]os.FileInfo, error) {
+	rel, err := relPath(uri)
+	if err != nil {
+		return nil, files.PathError("readdir", uri.String(), err)
+	}
+
+	dirs, err := h.base.dirs()
+	if err != nil {
+		return nil, files.PathError("readdir", uri.String(), err)
+	}
+
+	seen := make(map[string]bool)
+	var infos []os.FileInfo
+	var found bool
+
+	for _, dir := range dirs {
+		entries, err := ioutil.ReadDir(filepath.Join(dir, rel))
+		if err != nil {
+			continue
+		}
+		found = true
+
+		for _, fi := range entries {
+			if seen[fi.Name()] {
+				continue
+			}
+			seen[fi.Name()] = true
+
+			infos = append(infos, fi)
+		}
+	}
+
+	if !found {
+		return nil, files.PathError("readdir", uri.String(), os.ErrNotExist)
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name() < infos[j].Name()
+	})
+
+	return infos, nil
+}
@@ -0,0 +1,114 @@
+package xdg
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/puellanivis/breton/lib/files"
+)
+
+func TestXDG(t *testing.T) {
+	dir, err := ioutil.TempDir("", "xdg")
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+	defer os.RemoveAll(dir)
+
+	t.Setenv("HOME", dir+"/home")
+	t.Setenv("XDG_CONFIG_HOME", dir+"/config")
+	t.Setenv("XDG_CONFIG_DIRS", strings.Join([]string{dir + "/etc1", "relative/ignored", dir + "/etc2"}, string(os.PathListSeparator)))
+	t.Setenv("XDG_CACHE_HOME", "relative/ignored")
+	t.Setenv("XDG_RUNTIME_DIR", "")
+
+	content := map[string]string{
+		"config/app/user.conf":  "user",
+		"etc1/app/system.conf":  "etc1",
+		"etc2/app/system.conf":  "etc2",
+		"etc2/app/only.conf":    "only",
+		"etc2/app/user.conf":    "shadowed",
+		"relative/ignored/file": "ignored",
+	}
+
+	for name, value := range content {
+		name = filepath.Join(dir, filepath.FromSlash(name))
+
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal("unexpected error", err)
+		}
+
+		if err := ioutil.WriteFile(name, []byte(value), 0644); err != nil {
+			t.Fatal("unexpected error", err)
+		}
+	}
+
+	ctx := context.Background()
+
+	tests := []struct {
+		url    string
+		expect string
+	}{
+		{"xdg-config:app/user.conf", "user"},
+		{"xdg-config:app/system.conf", "etc1"},
+		{"xdg-config:/app/only.conf", "only"},
+	}
+
+	for _, tt := range tests {
+		b, err := files.Read(ctx, tt.url)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.url, err)
+		}
+
+		if string(b) != tt.expect {
+			t.Errorf("%s: got %q, expected %q", tt.url, b, tt.expect)
+		}
+	}
+
+	if _, err := files.Read(ctx, "xdg-config:app/missing.conf"); !os.IsNotExist(err) {
+		t.Errorf("got %v, expected a not exist error", err)
+	}
+
+	infos, err := files.ReadDir(ctx, "xdg-config:app")
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	var names []string
+	for _, fi := range infos {
+		names = append(names, fi.Name())
+	}
+
+	if got, expect := strings.Join(names, ","), "only.conf,system.conf,user.conf"; got != expect {
+		t.Errorf("got listing %q, expected %q", got, expect)
+	}
+
+	// A relative XDG_CACHE_HOME is ignored, so the default under HOME is used, and created.
+	if err := files.Write(ctx, "xdg-cache:app/sub/cache.db", []byte("cache")); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	fi, err := os.Stat(filepath.Join(dir, "home", ".cache", "app", "sub"))
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if perm := fi.Mode().Perm(); perm != 0700 {
+		t.Errorf("got created directory permissions %v, expected %v", perm, os.FileMode(0700))
+	}
+
+	b, err := files.Read(ctx, "xdg-cache:app/sub/cache.db")
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if string(b) != "cache" {
+		t.Errorf("got %q, expected %q", b, "cache")
+	}
+
+	if _, err := files.Create(ctx, "xdg-runtime:app.sock"); err == nil {
+		t.Error("expected an error with XDG_RUNTIME_DIR not set, got none")
+	}
+}
@@ -0,0 +1,74 @@
+package throttle
+
+import (
+	"context"
+	"io"
+)
+
+type writer struct {
+	ctx context.Context
+	w   io.Writer
+	l   *Limiter
+}
+
+// NewWriter returns an io.Writer that paces each Write to w with the Limiter.
+// A Write waiting on the Limiter returns early with an error, if the given context.Context is canceled.
+// If a nil context is given, then no context-dependent cancelation will be done.
+func NewWriter(ctx context.Context, w io.Writer, l *Limiter) io.Writer {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	return &writer{
+		ctx: ctx,
+		w:   w,
+		l:   l,
+	}
+}
+
+func (w *writer) Write(b []byte) (n int, err error) {
+	if err := w.l.Wait(w.ctx, len(b)); err != nil {
+		return 0, err
+	}
+
+	return w.w.Write(b)
+}
+
+type reader struct {
+	ctx context.Context
+	r   io.Reader
+	l   *Limiter
+}
+
+// NewReader returns an io.Reader that paces Reads from r with the Limiter.
+// A Read waiting on the Limiter returns early with an error, if the given context.Context is canceled.
+// If a nil context is given, then no context-dependent cancelation will be done.
+//
+// As how much a Read will return is not known beforehand,
+// the bytes read are paid for after the Read, and delay the next Read instead.
+func NewReader(ctx context.Context, r io.Reader, l *Limiter) io.Reader {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	return &reader{
+		ctx: ctx,
+		r:   r,
+		l:   l,
+	}
+}
+
+func (r *reader) Read(b []byte) (n int, err error) {
+	// Wait out any debt from previous Reads.
+	if err := r.l.Wait(r.ctx, 0); err != nil {
+		return 0, err
+	}
+
+	n, err = r.r.Read(b)
+
+	if n > 0 {
+		_ = r.l.reserve(n)
+	}
+
+	return n, err
+}
@@ -0,0 +1,45 @@
+package throttle
+
+import (
+	"time"
+)
+
+// Option defines a function that will apply a specific value or feature to a given Limiter.
+type Option func(*Limiter) Option
+
+// WithBurst sets the number of bits that may be sent at full speed after being idle.
+// A burst of zero paces every send, no matter how long it has been idle.
+func WithBurst(bits int) Option {
+	return func(l *Limiter) Option {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		save := l.burst
+
+		l.burst = bits
+
+		return WithBurst(save)
+	}
+}
+
+// WithCatchUp sets how far behind the bitrate a stream may fall, and still be allowed to catch up by sending faster.
+//
+// A catch-up of zero means time not spent sending is lost, beyond the burst size.
+// A negative catch-up means the stream may always catch up fully, keeping the long-term average at the bitrate.
+func WithCatchUp(d time.Duration) Option {
+	return func(l *Limiter) Option {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		save := l.catchUp
+
+		l.catchUp = d
+
+		return WithCatchUp(save)
+	}
+}
+
+// CatchUpFull is a more readable version of WithCatchUp(-1).
+func CatchUpFull() Option {
+	return WithCatchUp(-1)
+}
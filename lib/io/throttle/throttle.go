@@ -0,0 +1,157 @@
+// Package throttle implements a token-bucket pacer to limit the bitrate of reads and writes.
+package throttle
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter paces a stream of bytes to a maximum bitrate with a token bucket.
+//
+// Tokens accumulate at the bitrate, and each byte sent spends one byte worth of tokens.
+// A send is allowed to go ahead as long as the bucket is not in debt,
+// so sends are never split, and a large send delays the next one instead.
+//
+// While idle, the bucket fills up to the burst size, which may then be sent at full speed.
+// The catch-up allows the bucket to fill further, so that a stream that has fallen behind,
+// say because of a stalled reader, may send faster until it has caught up with the bitrate.
+//
+// A Limiter accounts against the clock, rather than sleeping a fixed delay for each send,
+// so oversleeping due to scheduler jitter is made up for, rather than adding up.
+type Limiter struct {
+	mu sync.Mutex
+
+	bitrate int
+	burst   int
+	catchUp time.Duration
+
+	tokens float64 // in bytes, may be negative when in debt.
+	last   time.Time
+}
+
+// New returns a Limiter with the given maximum bitrate, in bits per second, and Options.
+// A bitrate that is zero or less is unlimited.
+func New(bitrate int, opts ...Option) *Limiter {
+	l := &Limiter{
+		bitrate: bitrate,
+		last:    time.Now(),
+	}
+
+	for _, opt := range opts {
+		_ = opt(l)
+	}
+
+	// Start with a full burst, but not any catch-up, as nothing has fallen behind yet.
+	l.tokens = float64(l.burst) / 8
+
+	return l
+}
+
+// rate returns the rate that tokens accumulate in bytes per second.
+//
+// Caller MUST hold the lock.
+func (l *Limiter) rate() float64 {
+	return float64(l.bitrate) / 8
+}
+
+// max returns the most bytes of tokens that the bucket may hold, or a negative value if there is no maximum.
+//
+// Caller MUST hold the lock.
+func (l *Limiter) max() float64 {
+	if l.catchUp < 0 {
+		return -1
+	}
+
+	return float64(l.burst)/8 + l.rate()*l.catchUp.Seconds()
+}
+
+// refill adds the tokens accumulated since the last refill.
+//
+// Caller MUST hold the lock.
+func (l *Limiter) refill(now time.Time) {
+	l.tokens += l.rate() * now.Sub(l.last).Seconds()
+	l.last = now
+
+	if max := l.max(); max >= 0 && l.tokens > max {
+		l.tokens = max
+	}
+}
+
+// reserve spends n bytes of tokens, and returns how long to wait before they may be sent.
+func (l *Limiter) reserve(n int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.bitrate <= 0 {
+		return 0
+	}
+
+	l.refill(time.Now())
+
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate() * float64(time.Second))
+	}
+
+	l.tokens -= float64(n)
+
+	return delay
+}
+
+// Wait blocks until n bytes may be sent, or the context is done.
+func (l *Limiter) Wait(ctx context.Context, n int) error {
+	delay := l.reserve(n)
+	if delay <= 0 {
+		return nil
+	}
+
+	t := time.NewTimer(delay)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Bitrate returns the maximum bitrate in bits per second.
+func (l *Limiter) Bitrate() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.bitrate
+}
+
+// SetBitrate sets the maximum bitrate in bits per second, and returns the previous value.
+// A bitrate that is zero or less is unlimited.
+func (l *Limiter) SetBitrate(bitrate int) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Settle the tokens accumulated at the old bitrate.
+	l.refill(time.Now())
+
+	prev := l.bitrate
+	l.bitrate = bitrate
+
+	return prev
+}
+
+// Burst returns the burst size in bits.
+func (l *Limiter) Burst() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.burst
+}
+
+// CatchUp returns the catch-up duration.
+func (l *Limiter) CatchUp() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.catchUp
+}
@@ -0,0 +1,157 @@
+package throttle
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	// 8 kbps is 1000 bytes per second.
+	l := New(8000)
+
+	start := time.Now()
+
+	for i := 0; i < 5; i++ {
+		if err := l.Wait(context.Background(), 100); err != nil {
+			t.Fatal("unexpected error", err)
+		}
+	}
+
+	// The first send goes immediately, and the last waits for the four before it.
+	if d := time.Since(start); d < 350*time.Millisecond || d > 600*time.Millisecond {
+		t.Errorf("sending 500 bytes took %v, expected about 400ms", d)
+	}
+}
+
+func TestLimiterBurst(t *testing.T) {
+	l := New(8000, WithBurst(8*400))
+
+	start := time.Now()
+
+	for i := 0; i < 5; i++ {
+		if err := l.Wait(context.Background(), 100); err != nil {
+			t.Fatal("unexpected error", err)
+		}
+	}
+
+	if d := time.Since(start); d > 100*time.Millisecond {
+		t.Errorf("sending 500 bytes took %v, expected the burst to go immediately", d)
+	}
+}
+
+func TestLimiterCatchUp(t *testing.T) {
+	l := New(8000, WithCatchUp(200*time.Millisecond))
+
+	// Fall behind by more than the catch-up allows.
+	time.Sleep(300 * time.Millisecond)
+
+	l.mu.Lock()
+	l.refill(time.Now())
+	tokens := l.tokens
+	l.mu.Unlock()
+
+	if tokens > 200 {
+		t.Errorf("got %v bytes of tokens, expected no more than 200", tokens)
+	}
+
+	l = New(8000, CatchUpFull())
+	l.last = l.last.Add(-10 * time.Second)
+
+	start := time.Now()
+
+	if err := l.Wait(context.Background(), 5000); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if err := l.Wait(context.Background(), 4000); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if d := time.Since(start); d > 100*time.Millisecond {
+		t.Errorf("catching up took %v, expected to go immediately", d)
+	}
+}
+
+func TestLimiterCatchUpFullStart(t *testing.T) {
+	l := New(8000, WithBurst(8*400), CatchUpFull())
+
+	l.mu.Lock()
+	tokens := l.tokens
+	l.mu.Unlock()
+
+	if tokens != 400 {
+		t.Errorf("got %v bytes of tokens, expected the burst of 400", tokens)
+	}
+
+	start := time.Now()
+
+	for i := 0; i < 5; i++ {
+		if err := l.Wait(context.Background(), 100); err != nil {
+			t.Fatal("unexpected error", err)
+		}
+	}
+
+	if d := time.Since(start); d > 100*time.Millisecond {
+		t.Errorf("sending 500 bytes took %v, expected the burst to go immediately", d)
+	}
+
+	if err := l.Wait(context.Background(), 100); err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if d := time.Since(start); d < 50*time.Millisecond {
+		t.Errorf("sending 600 bytes took %v, expected to be paced after the burst", d)
+	}
+}
+
+func TestLimiterContext(t *testing.T) {
+	l := New(8)
+
+	_ = l.Wait(context.Background(), 100)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := l.Wait(ctx, 1); err != context.DeadlineExceeded {
+		t.Errorf("got error %v, expected %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestReaderWriter(t *testing.T) {
+	l := New(80000) // 10000 bytes per second.
+
+	var buf bytes.Buffer
+	w := NewWriter(context.Background(), &buf, l)
+
+	start := time.Now()
+
+	for i := 0; i < 3; i++ {
+		if _, err := w.Write(make([]byte, 1000)); err != nil {
+			t.Fatal("unexpected error", err)
+		}
+	}
+
+	if d := time.Since(start); d < 150*time.Millisecond {
+		t.Errorf("writing took %v, expected about 200ms", d)
+	}
+
+	r := NewReader(nil, bytes.NewReader(make([]byte, 3000)), l)
+
+	start = time.Now()
+
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal("unexpected error", err)
+	}
+
+	if len(b) != 3000 {
+		t.Errorf("got %d bytes, expected %d", len(b), 3000)
+	}
+
+	if d := time.Since(start); d < 50*time.Millisecond {
+		t.Errorf("reading took %v, expected to be paced", d)
+	}
+}